@@ -0,0 +1,141 @@
+package ga4mp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// split divides r into requests of at most 25 events,
+// each sharing the request level fields of r
+func split(r *Request) []*Request {
+	if len(r.Events) <= maxEvents {
+		return []*Request{r}
+	}
+	var rs []*Request
+	for i := 0; i < len(r.Events); i += maxEvents {
+		end := i + maxEvents
+		if end > len(r.Events) {
+			end = len(r.Events)
+		}
+		rr := *r
+		rr.Events = r.Events[i:end]
+		rs = append(rs, &rr)
+	}
+	return rs
+}
+
+// PlanBatches reports how many requests r would be split into
+// to stay within the per request event limit,
+// and the total size of their bodies, without sending anything.
+// It fails if any of the resulting requests exceeds the other limits.
+func PlanBatches(r *Request) (numRequests int, totalBytes int, err error) {
+	for i, rr := range split(r) {
+		b, err := encodeRequest(rr)
+		if err != nil {
+			return 0, 0, fmt.Errorf("ga4mp: marshal request %d: %w", i, err)
+		}
+		if err := rr.checkLimits(len(b), defaultLimits); err != nil {
+			return 0, 0, fmt.Errorf("ga4mp: check limits request %d: %w", i, err)
+		}
+		numRequests++
+		totalBytes += len(b)
+	}
+	return numRequests, totalBytes, nil
+}
+
+// BatchResult is the outcome of one request sent by a batch method
+type BatchResult struct {
+	// Index of the request in the input
+	Index int
+	Err   error
+	// Response status, 0 if no response was received
+	StatusCode int
+}
+
+// SendMany sends each of rs, at most ClientOptions.Concurrency at a time.
+// Results are indexed like rs,
+// requests not started before ctx is done report ctx.Err().
+func (c *Client) SendMany(ctx context.Context, rs []*Request) []BatchResult {
+	results := make([]BatchResult, len(rs))
+	parallel(ctx, len(rs), c.concurrency, func(i int) {
+		code, err := c.send(ctx, rs[i])
+		results[i] = BatchResult{Index: i, Err: err, StatusCode: code}
+	}, func(i int) {
+		results[i] = BatchResult{Index: i, Err: ctx.Err()}
+	})
+	return results
+}
+
+// SendSplit sends r as requests of up to 25 events,
+// see PlanBatches. Result indices are those of the split requests,
+// the events of result i start at r.Events[i*25].
+func (c *Client) SendSplit(ctx context.Context, r *Request) []BatchResult {
+	return c.SendMany(ctx, split(r))
+}
+
+// PipeSend sends rs one after the other, for requests of different users
+// that can't be merged into one request.
+// Sending back to back lets the HttpClient transport reuse one keep-alive connection,
+// avoiding a TLS handshake per request,
+// so it relies on a transport with keep-alives enabled, like http.DefaultTransport.
+// Errors are indexed like rs,
+// requests not sent before ctx is done report ctx.Err().
+func (c *Client) PipeSend(ctx context.Context, rs []*Request) []error {
+	errs := make([]error, len(rs))
+	for i, r := range rs {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		_, errs[i] = c.send(ctx, r)
+	}
+	return errs
+}
+
+// BackfillResult counts the events of a Backfill
+type BackfillResult struct {
+	Sent int
+	// Events that had aged past the 72 hour window when their turn came
+	Skipped int
+}
+
+// Backfill sends historical events of clientID, oldest first,
+// in requests of up to 25 events.
+// Every event needs a TimestampMicros. Each request is checked against the window
+// right before it is sent, events that aged out while earlier requests were sent are skipped.
+// It stops at the first failed request.
+func (c *Client) Backfill(ctx context.Context, clientID string, events []Event) (BackfillResult, error) {
+	var res BackfillResult
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	for i, e := range sorted {
+		if e.TimestampMicros == 0 {
+			return res, fmt.Errorf("ga4mp: backfill: events[%d] (%s) has no timestamp", i, e.Name)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TimestampMicros < sorted[j].TimestampMicros
+	})
+
+	for len(sorted) > 0 {
+		cutoff := c.now().Add(-maxTimestampAge).UnixMicro()
+		for len(sorted) > 0 && sorted[0].TimestampMicros < cutoff {
+			sorted = sorted[1:]
+			res.Skipped++
+		}
+		n := len(sorted)
+		if n > maxEvents {
+			n = maxEvents
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := c.send(ctx, &Request{ClientID: clientID, Events: sorted[:n]}); err != nil {
+			return res, err
+		}
+		res.Sent += n
+		sorted = sorted[n:]
+	}
+	return res, nil
+}
@@ -0,0 +1,287 @@
+package ga4mp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what happens to events added to a full Batcher
+type OverflowPolicy int
+
+const (
+	// Block waits for space or for the context to be done
+	Block OverflowPolicy = iota
+	// DropNewest discards the events being added
+	DropNewest
+	// DropOldest discards the oldest buffered events to make space
+	DropOldest
+)
+
+type BatcherOptions struct {
+	// Maximum number of buffered events, defaults to 1000
+	Buffer int
+	// What to do with events added while the buffer is full,
+	// defaults to Block
+	Overflow OverflowPolicy
+	// Flush once a client id has this many events buffered,
+	// defaults to and is capped at 25, the events of one request
+	FlushEvents int
+	// Flush when this long has passed since the last flush, defaults to 5s
+	FlushInterval time.Duration
+}
+
+// Batcher buffers events in the background,
+// sending them in requests of up to 25 events per client id
+// every FlushInterval, or sooner once a client id has FlushEvents events buffered.
+// Send errors are reported through ClientOptions.Warn.
+// Client.Shutdown closes all Batchers of the Client.
+type Batcher struct {
+	c *Client
+	o BatcherOptions
+
+	mu       sync.Mutex
+	pending  []batchEvent
+	counts   map[string]int
+	space    chan struct{} // closed when pending is flushed
+	dropped  uint64
+	closed   bool
+	aborted  int // events lost in a flush cancelled by Close
+	abortErr error
+
+	ctx     context.Context // cancelled when Close gives up on background flushes
+	cancel  context.CancelFunc
+	kick    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+type batchEvent struct {
+	clientID string
+	event    Event
+}
+
+func NewBatcher(c *Client, o BatcherOptions) *Batcher {
+	if o.Buffer <= 0 {
+		o.Buffer = 1000
+	}
+	if o.FlushEvents <= 0 || o.FlushEvents > maxEvents {
+		o.FlushEvents = maxEvents
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Batcher{
+		ctx:     ctx,
+		cancel:  cancel,
+		c:       c,
+		o:       o,
+		counts:  make(map[string]int),
+		space:   make(chan struct{}),
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		b.closed = true
+		close(b.done)
+	} else {
+		if c.batchers == nil {
+			c.batchers = make(map[*Batcher]struct{})
+		}
+		c.batchers[b] = struct{}{}
+	}
+	c.mu.Unlock()
+
+	go b.run()
+	return b
+}
+
+// Add buffers events for clientID
+func (b *Batcher) Add(ctx context.Context, clientID string, events ...Event) error {
+	for _, e := range events {
+		if err := b.add(ctx, batchEvent{clientID: clientID, event: e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Batcher) add(ctx context.Context, be batchEvent) error {
+	b.mu.Lock()
+	for !b.closed && len(b.pending) >= b.o.Buffer {
+		switch b.o.Overflow {
+		case DropNewest:
+			b.dropped++
+			b.mu.Unlock()
+			b.c.warn("ga4mp: batcher full, dropped new event %q", be.event.Name)
+			return nil
+		case DropOldest:
+			old := b.pending[0]
+			b.pending = b.pending[1:]
+			b.counts[old.clientID]--
+			b.dropped++
+			b.mu.Unlock()
+			b.c.warn("ga4mp: batcher full, dropped old event %q", old.event.Name)
+			b.mu.Lock()
+		default:
+			space := b.space
+			b.mu.Unlock()
+			b.flushSoon()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			b.mu.Lock()
+		}
+	}
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.pending = append(b.pending, be)
+	b.counts[be.clientID]++
+	full := b.counts[be.clientID] >= b.o.FlushEvents
+	b.mu.Unlock()
+
+	if full {
+		b.flushSoon()
+	}
+	return nil
+}
+
+// Dropped returns the number of events discarded by the OverflowPolicy
+func (b *Batcher) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// FlushError reports the events a Batcher failed to send while closing
+type FlushError struct {
+	Lost int
+	// The first send error
+	Err error
+}
+
+func (e *FlushError) Error() string {
+	return fmt.Sprintf("ga4mp: batcher lost %d events: %v", e.Lost, e.Err)
+}
+
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// Close stops the background flushes and sends the remaining events,
+// further calls to Add return ErrClosed.
+// If ctx is done first, in flight sends are aborted,
+// and the events that weren't sent are reported in a *FlushError.
+func (b *Batcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	b.c.mu.Lock()
+	delete(b.c.batchers, b)
+	b.c.mu.Unlock()
+
+	close(b.done)
+	defer b.cancel()
+	select {
+	case <-b.stopped:
+	case <-ctx.Done():
+		b.cancel()
+		<-b.stopped
+	}
+
+	lost, err := b.flush(ctx)
+	b.mu.Lock()
+	lost += b.aborted
+	if err == nil {
+		err = b.abortErr
+	}
+	b.mu.Unlock()
+	if lost > 0 {
+		return &FlushError{Lost: lost, Err: err}
+	}
+	return nil
+}
+
+func (b *Batcher) flushSoon() {
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Batcher) run() {
+	defer close(b.stopped)
+	t := time.NewTimer(b.o.FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.kick:
+			if !t.Stop() {
+				<-t.C
+			}
+		case <-t.C:
+		case <-b.done:
+			return
+		}
+		lost, err := b.flush(b.ctx)
+		t.Reset(b.o.FlushInterval)
+		if lost == 0 {
+			continue
+		}
+		if b.ctx.Err() != nil {
+			b.mu.Lock()
+			b.aborted += lost
+			b.abortErr = err
+			b.mu.Unlock()
+			return
+		}
+		b.c.warn("ga4mp: batcher lost %d events: %v", lost, err)
+	}
+}
+
+// flush sends all buffered events,
+// returning the number that weren't sent and the first error
+func (b *Batcher) flush(ctx context.Context) (lost int, firstErr error) {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.counts = make(map[string]int)
+	close(b.space)
+	b.space = make(chan struct{})
+	b.mu.Unlock()
+
+	var order []string
+	byClient := make(map[string][]Event)
+	for _, be := range pending {
+		if _, ok := byClient[be.clientID]; !ok {
+			order = append(order, be.clientID)
+		}
+		byClient[be.clientID] = append(byClient[be.clientID], be.event)
+	}
+	for _, clientID := range order {
+		r := &Request{ClientID: clientID, Events: byClient[clientID]}
+		for _, rr := range split(r) {
+			if _, _, err := b.c.deliver(ctx, rr); err != nil {
+				lost += len(rr.Events)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	return lost, firstErr
+}
@@ -0,0 +1,68 @@
+package ga4mp
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending while the circuit breaker is open
+var ErrCircuitOpen = errors.New("ga4mp: circuit open")
+
+// breaker opens after threshold consecutive failures,
+// rejecting requests until cooldown has passed.
+// It then lets a single probe through, closing on success
+// and opening for another cooldown on failure.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a request may be sent,
+// and whether it is the probe of an open circuit
+func (b *breaker) allow(now time.Time) (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if b.probing || now.Before(b.openUntil) {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// record counts the result of an allowed request,
+// only the probe's result ends probing
+func (b *breaker) record(probe, ok bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
+
+// release ends an allowed request without counting its result,
+// such as one cancelled by the caller
+func (b *breaker) release(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
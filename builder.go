@@ -0,0 +1,120 @@
+package ga4mp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RequestBuilder builds a Request with chained setters,
+// collecting the problems found along the way for Build to return
+type RequestBuilder struct {
+	r    Request
+	errs BuildErrors
+}
+
+// BuildErrors is the list of problems found by a RequestBuilder
+type BuildErrors []error
+
+func (e BuildErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// NewRequest starts building a request for clientID
+func NewRequest(clientID string) *RequestBuilder {
+	return &RequestBuilder{r: Request{ClientID: clientID}}
+}
+
+func (b *RequestBuilder) UserID(id string) *RequestBuilder {
+	b.r.UserID = id
+	return b
+}
+
+func (b *RequestBuilder) UserProperty(name, value string) *RequestBuilder {
+	if err := validName(name, reservedUserProperties, reservedUserPropertyPrefix); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("invalid user property name: %w", err))
+	}
+	if b.r.UserProperties == nil {
+		b.r.UserProperties = make(map[string]string)
+	}
+	b.r.UserProperties[name] = value
+	return b
+}
+
+func (b *RequestBuilder) Event(e Event) *RequestBuilder {
+	if err := e.validate(); err != nil {
+		b.errs = append(b.errs, eventError(len(b.r.Events), e, err))
+	}
+	b.r.Events = append(b.r.Events, e)
+	return b
+}
+
+// Timestamp backdates the events without a timestamp of their own to t,
+// Build checks it is within the accepted window
+func (b *RequestBuilder) Timestamp(t time.Time) *RequestBuilder {
+	b.r.TimestampMicros = t.UnixMicro()
+	return b
+}
+
+func (b *RequestBuilder) NonPersonalizedAds(npa bool) *RequestBuilder {
+	b.r.NonPersonalizedAds = npa
+	return b
+}
+
+// Build returns the request, or the BuildErrors from the setters.
+// Without those, the request is checked with Request.Validate.
+func (b *RequestBuilder) Build() (*Request, error) {
+	if len(b.errs) > 0 {
+		return nil, b.errs
+	}
+	r := b.r.clone()
+	if err := r.Validate(); err != nil {
+		return nil, BuildErrors{err}
+	}
+	return r, nil
+}
+
+// EventBuilder builds an Event, turning arbitrary names such as "Item Name"
+// into valid snake_case names
+type EventBuilder struct {
+	e    Event
+	keys map[string]string // sanitized param name to the original key
+	errs BuildErrors
+}
+
+// NewEvent starts building an event, name is sanitized like param keys
+func NewEvent(name string) *EventBuilder {
+	return &EventBuilder{
+		e:    Event{Name: snakeName(name), Params: make(map[string]interface{})},
+		keys: make(map[string]string),
+	}
+}
+
+// Param sets the param named after the sanitized key.
+// Distinct keys that sanitize to the same name are an error, instead of overwriting each other.
+func (b *EventBuilder) Param(key string, v interface{}) *EventBuilder {
+	name := snakeName(key)
+	if prev, ok := b.keys[name]; ok && prev != key {
+		b.errs = append(b.errs, fmt.Errorf("params %q and %q both sanitize to %q", prev, key, name))
+		return b
+	}
+	b.keys[name] = key
+	b.e.Params[name] = v
+	return b
+}
+
+// Build returns the event, or the BuildErrors from Param and Event.Validate
+func (b *EventBuilder) Build() (Event, error) {
+	errs := b.errs
+	if err := b.e.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return Event{}, errs
+	}
+	return b.e.clone(), nil
+}
@@ -0,0 +1,92 @@
+package ga4mp
+
+import "fmt"
+
+// consent values
+const (
+	ConsentGranted = "GRANTED"
+	ConsentDenied  = "DENIED"
+)
+
+// Consent is the consent object of a request,
+// each field is ConsentGranted, ConsentDenied or unset
+type Consent struct {
+	// Whether the user data of the request can be used for advertising
+	AdUserData string `json:"ad_user_data,omitempty"`
+	// Whether the request can be used for ads personalization
+	AdPersonalization string `json:"ad_personalization,omitempty"`
+}
+
+// ConsentState is the consent of a user as tracked by the app
+type ConsentState struct {
+	AdUserData        bool
+	AdPersonalization bool
+}
+
+// Apply sets the consent object and NonPersonalizedAds of r from s,
+// so they don't contradict each other
+func (s ConsentState) Apply(r *Request) {
+	r.Consent = &Consent{
+		AdUserData:        consentValue(s.AdUserData),
+		AdPersonalization: consentValue(s.AdPersonalization),
+	}
+	r.NonPersonalizedAds = !s.AdPersonalization
+}
+
+func consentValue(granted bool) string {
+	if granted {
+		return ConsentGranted
+	}
+	return ConsentDenied
+}
+
+// validate checks the values of c,
+// and that ad_personalization agrees with non_personalized_ads
+func (c *Consent) validate(nonPersonalizedAds bool) error {
+	for _, v := range []struct{ name, value string }{
+		{"ad_user_data", c.AdUserData},
+		{"ad_personalization", c.AdPersonalization},
+	} {
+		if v.value != "" && v.value != ConsentGranted && v.value != ConsentDenied {
+			return fmt.Errorf("%s must be %s or %s: %q", v.name, ConsentGranted, ConsentDenied, v.value)
+		}
+	}
+	if c.AdPersonalization == ConsentGranted && nonPersonalizedAds {
+		return fmt.Errorf("ad_personalization granted with non_personalized_ads set")
+	}
+	if c.AdPersonalization == ConsentDenied && !nonPersonalizedAds {
+		return fmt.Errorf("ad_personalization denied without non_personalized_ads set")
+	}
+	return nil
+}
+
+// request level ad settings that can't vary per event
+var adSettingParams = map[string]struct{}{
+	"non_personalized_ads": {},
+	"ad_user_data":         {},
+	"ad_personalization":   {},
+	"consent":              {},
+}
+
+// SplitByConsent partitions events into requests of the same consent,
+// as GA4 only supports ad settings per request.
+// The requests are in order of their first event, with Consent and NonPersonalizedAds set
+// by ConsentState.Apply and at most 25 events. Set their ClientID before sending.
+func SplitByConsent(events []Event, consent func(Event) ConsentState) []*Request {
+	var order []ConsentState
+	groups := make(map[ConsentState][]Event)
+	for _, e := range events {
+		s := consent(e)
+		if _, ok := groups[s]; !ok {
+			order = append(order, s)
+		}
+		groups[s] = append(groups[s], e)
+	}
+	var rs []*Request
+	for _, s := range order {
+		r := &Request{Events: groups[s]}
+		s.Apply(r)
+		rs = append(rs, split(r)...)
+	}
+	return rs
+}
@@ -0,0 +1,102 @@
+package ga4mp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DebugResult is the validation of one line read by DebugReader
+type DebugResult struct {
+	// 1 based line number in the input, 0 for read errors
+	Line     int
+	Response ValidationResponse
+	// A parse error for malformed lines, or the error from Debug
+	Err error
+}
+
+// DebugReader reads one JSON encoded Request per line of r,
+// validating each with Debug, at most ClientOptions.Concurrency at a time.
+// Results are streamed in completion order on the returned channel,
+// which is closed after the last line, a read error, or once ctx is done.
+// Lines are decoded like UnmarshalRequest, blank lines are skipped,
+// malformed and overlong lines get an error result without stopping the stream.
+func (c *Client) DebugReader(ctx context.Context, r io.Reader) (<-chan DebugResult, error) {
+	if !c.debug {
+		return nil, ErrNotDebug
+	} else if c.isClosed() {
+		return nil, ErrClosed
+	}
+
+	results := make(chan DebugResult)
+	emit := func(res DebugResult) {
+		select {
+		case results <- res:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(results)
+		sem := make(chan struct{}, c.concurrency)
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		br := bufio.NewReader(r)
+		for line := 1; ; line++ {
+			b, tooLong, err := readLine(br, maxLineBytes)
+			if tooLong {
+				emit(DebugResult{Line: line, Err: fmt.Errorf("ga4mp: parse line %d: longer than %d bytes", line, maxLineBytes)})
+			} else if len(bytes.TrimSpace(b)) > 0 {
+				if req, perr := unmarshalRequest(b); perr != nil {
+					emit(DebugResult{Line: line, Err: fmt.Errorf("ga4mp: parse line %d: %w", line, perr)})
+				} else {
+					select {
+					case sem <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					wg.Add(1)
+					go func(line int) {
+						defer func() {
+							<-sem
+							wg.Done()
+						}()
+						msg, err := c.Debug(ctx, req)
+						emit(DebugResult{Line: line, Response: msg, Err: err})
+					}(line)
+				}
+			}
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				emit(DebugResult{Err: fmt.Errorf("ga4mp: read requests: %w", err)})
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// longest line DebugReader reads, longer ones are reported and skipped
+const maxLineBytes = 4 * maxPayloadBytes
+
+// readLine reads the next line of br, without buffering more than max bytes,
+// reporting lines longer than that as tooLong after discarding them
+func readLine(br *bufio.Reader, max int) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > max {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err != bufio.ErrBufferFull {
+			return line, tooLong, err
+		}
+	}
+}
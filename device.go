@@ -0,0 +1,38 @@
+package ga4mp
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Device overrides the device information of a request
+// https://developers.google.com/analytics/devguides/collection/protocol/ga4/reference#device
+type Device struct {
+	// eg. desktop, tablet, mobile, smart TV
+	Category string `json:"category,omitempty"`
+	// BCP 47 language tag, eg. en-US
+	Language string `json:"language,omitempty"`
+	// WIDTHxHEIGHT, eg. 1280x2856
+	ScreenResolution       string `json:"screen_resolution,omitempty"`
+	OperatingSystem        string `json:"operating_system,omitempty"`
+	OperatingSystemVersion string `json:"operating_system_version,omitempty"`
+	Model                  string `json:"model,omitempty"`
+	Brand                  string `json:"brand,omitempty"`
+	Browser                string `json:"browser,omitempty"`
+	BrowserVersion         string `json:"browser_version,omitempty"`
+}
+
+var (
+	languageRe   = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+	resolutionRe = regexp.MustCompile(`^[0-9]+x[0-9]+$`)
+)
+
+func (d Device) validate() error {
+	if d.Language != "" && !languageRe.MatchString(d.Language) {
+		return fmt.Errorf("language not a BCP 47 tag: %q", d.Language)
+	}
+	if d.ScreenResolution != "" && !resolutionRe.MatchString(d.ScreenResolution) {
+		return fmt.Errorf("screen_resolution not WIDTHxHEIGHT: %q", d.ScreenResolution)
+	}
+	return nil
+}
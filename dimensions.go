@@ -0,0 +1,45 @@
+package ga4mp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dimensions are the param names registered for custom dimensions and metrics,
+// by their folded form
+type dimensions map[string]string
+
+func newDimensions(names []string) dimensions {
+	d := make(dimensions, len(names))
+	for _, n := range names {
+		d[foldDimension(n)] = n
+	}
+	return d
+}
+
+// foldDimension ignores case and underscores,
+// the usual ways a param name is misspelled
+func foldDimension(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "_", "")
+}
+
+// validate rejects params that look like a registered name but don't match it exactly
+func (d dimensions) validate(r *Request) error {
+	for i, e := range r.Events {
+		for k := range e.Params {
+			if name, ok := d[foldDimension(k)]; ok && name != k {
+				return eventError(i, e, fmt.Errorf("parameter %q doesn't match custom dimension %q", k, name))
+			}
+		}
+	}
+	return nil
+}
+
+// CustomDimension returns name if it is one of ClientOptions.CustomDimensions,
+// for use as a param key that is sure to populate the dimension
+func (c *Client) CustomDimension(name string) (string, error) {
+	if registered, ok := c.dimensions[foldDimension(name)]; ok && registered == name {
+		return name, nil
+	}
+	return "", fmt.Errorf("ga4mp: %q is not a registered custom dimension", name)
+}
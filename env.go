@@ -0,0 +1,43 @@
+package ga4mp
+
+import (
+	"fmt"
+	"os"
+)
+
+// Environment variables read by NewFromEnv,
+// change them before calling it to use other names
+var (
+	EnvMeasurementID = "GA4_MEASUREMENT_ID"
+	EnvApiSecret     = "GA4_API_SECRET"
+)
+
+// NewFromEnv returns a Client with the measurement id and api secret
+// of the EnvMeasurementID and EnvApiSecret environment variables,
+// and the default ClientOptions otherwise.
+// It fails if either is unset, empty or malformed.
+func NewFromEnv() (*Client, error) {
+	id, err := lookupEnv(EnvMeasurementID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := lookupEnv(EnvApiSecret)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCredentials("measurement_id", id, secret); err != nil {
+		return nil, err
+	}
+	return New(ClientOptions{MeasurementID: id, ApiSecret: secret}), nil
+}
+
+func lookupEnv(key string) (string, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("ga4mp: environment variable %s not set", key)
+	}
+	if v == "" {
+		return "", fmt.Errorf("ga4mp: environment variable %s is empty", key)
+	}
+	return v, nil
+}
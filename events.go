@@ -0,0 +1,177 @@
+package ga4mp
+
+import (
+	"sync"
+	"time"
+)
+
+// recommended events
+// https://developers.google.com/analytics/devguides/collection/ga4/reference/events
+const (
+	// all properties
+	EventLogin         = "login"
+	EventSearch        = "search"
+	EventSelectContent = "select_content"
+	EventShare         = "share"
+	EventSignUp        = "sign_up"
+
+	// online sales
+	EventAddPaymentInfo  = "add_payment_info"
+	EventAddShippingInfo = "add_shipping_info"
+	EventAddToCart       = "add_to_cart"
+	EventAddToWishlist   = "add_to_wishlist"
+	EventBeginCheckout   = "begin_checkout"
+	EventPurchase        = "purchase"
+	EventRefund          = "refund"
+	EventRemoveFromCart  = "remove_from_cart"
+	EventSelectItem      = "select_item"
+	EventSelectPromotion = "select_promotion"
+	EventViewCart        = "view_cart"
+	EventViewItem        = "view_item"
+	EventViewItemList    = "view_item_list"
+	EventViewPromotion   = "view_promotion"
+
+	// lead generation
+	EventGenerateLead       = "generate_lead"
+	EventQualifyLead        = "qualify_lead"
+	EventDisqualifyLead     = "disqualify_lead"
+	EventWorkingLead        = "working_lead"
+	EventCloseConvertLead   = "close_convert_lead"
+	EventCloseUnconvertLead = "close_unconvert_lead"
+
+	// games
+	EventEarnVirtualCurrency  = "earn_virtual_currency"
+	EventJoinGroup            = "join_group"
+	EventLevelEnd             = "level_end"
+	EventLevelStart           = "level_start"
+	EventLevelUp              = "level_up"
+	EventPostScore            = "post_score"
+	EventSpendVirtualCurrency = "spend_virtual_currency"
+	EventTutorialBegin        = "tutorial_begin"
+	EventTutorialComplete     = "tutorial_complete"
+	EventUnlockAchievement    = "unlock_achievement"
+
+	// the error event name is reserved, report errors with Exception
+	EventException = "exception"
+)
+
+// SessionStart returns a session_start event for a session that begins server side,
+// such as a user that only interacts through an API and has no client side tag.
+// It is the only way to send the reserved session_start name through validation.
+// Events sent as part of the session should carry the same session_id param.
+// Don't use it for users with a tag, that already starts sessions itself.
+func SessionStart(sessionID int64) Event {
+	return Event{
+		Name: "session_start",
+		Params: map[string]interface{}{
+			"session_id": sessionID,
+		},
+		reservedOK: true,
+	}
+}
+
+// FirstVisit returns a first_visit event for a user first seen server side,
+// with the same caveats as SessionStart.
+func FirstVisit() Event {
+	return Event{
+		Name:       "first_visit",
+		Params:     map[string]interface{}{},
+		reservedOK: true,
+	}
+}
+
+// Exception returns an exception event for an error,
+// fatal when it ended the user's session or the app.
+// Like other param values, description is limited to 100 characters,
+// longer descriptions fail the limit checks when sending.
+func Exception(description string, fatal bool) Event {
+	return Event{
+		Name: EventException,
+		Params: map[string]interface{}{
+			"description": description,
+			"fatal":       fatal,
+		},
+	}
+}
+
+// EngagementFrom returns the engagement_time_msec param for the time since start,
+// such as the start of the handler rendering a page,
+// clamped between 1ms and the 30 minute session timeout:
+//
+//	k, v := ga4mp.EngagementFrom(start)
+//	e.Params[k] = v
+//
+// Processing time is only a stand in for the time a user spent with the page,
+// it undercounts pages that are read for longer than they take to render.
+func EngagementFrom(start time.Time) (key string, value int64) {
+	ms := time.Since(start).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	} else if max := (30 * time.Minute).Milliseconds(); ms > max {
+		ms = max
+	}
+	return "engagement_time_msec", ms
+}
+
+// nameTracker counts distinct event names up to max
+type nameTracker struct {
+	max int
+
+	mu     sync.Mutex
+	seen   map[string]struct{}
+	warned bool
+}
+
+// track records the names of events,
+// reporting true the first time more than max names have been seen
+func (t *nameTracker) track(events []Event) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.warned {
+		return false
+	}
+	for _, e := range events {
+		if _, ok := t.seen[e.Name]; ok {
+			continue
+		}
+		if len(t.seen) == t.max {
+			t.warned = true
+			t.seen = nil
+			return true
+		}
+		t.seen[e.Name] = struct{}{}
+	}
+	return false
+}
+
+// internal gtag params this package can set,
+// exempt from parameter name validation.
+// They are undocumented for the measurement protocol,
+// Google may change how they are interpreted.
+//
+//	_fv                first visit of the user
+//	_nsi               first event of a new session
+//	ga_session_number  count of the user's sessions, set by SessionTracker
+var internalParams = map[string]struct{}{
+	"_fv":               {},
+	"_nsi":              {},
+	"ga_session_number": {},
+}
+
+// WithFirstVisit returns a copy of e flagged as the user's first visit,
+// for users first seen server side.
+func (e Event) WithFirstVisit() Event {
+	return e.withParam("_fv", 1)
+}
+
+// WithNewSession returns a copy of e flagged as starting a new session,
+// for sessions started server side.
+func (e Event) WithNewSession() Event {
+	return e.withParam("_nsi", 1)
+}
+
+func (e Event) withParam(k string, v interface{}) Event {
+	e = e.clone()
+	e.Params[k] = v
+	return e
+}
@@ -0,0 +1,85 @@
+package ga4mp
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Cart is the content of an ecommerce checkout, see Funnel
+type Cart struct {
+	// ISO 4217 code of Value, Tax, Shipping and the item prices
+	Currency string
+	Value    float64
+	Items    []Item
+	// Required by the purchase event
+	TransactionID string
+
+	Coupon      string
+	PaymentType string
+	Tax         float64
+	Shipping    float64
+}
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func (c Cart) validate() error {
+	if !currencyRe.MatchString(c.Currency) {
+		return fmt.Errorf("currency %q is not an ISO 4217 code", c.Currency)
+	}
+	if c.TransactionID == "" {
+		return fmt.Errorf("no transaction id")
+	}
+	if len(c.Items) == 0 {
+		return fmt.Errorf("no items")
+	}
+	if err := ValidateItems(c.Items); err != nil {
+		return fmt.Errorf("invalid items: %w", err)
+	}
+	if c.Value < 0 || c.Tax < 0 || c.Shipping < 0 {
+		return fmt.Errorf("negative value, tax or shipping")
+	}
+	return nil
+}
+
+// Funnel returns the ecommerce funnel of cart, in order:
+// view_item, add_to_cart, begin_checkout, add_payment_info and purchase,
+// all with the items, currency and value of cart.
+// Meant for exercising reports and integrations end to end,
+// real funnels are sent as each step happens.
+func Funnel(cart Cart) ([]Event, error) {
+	if err := cart.validate(); err != nil {
+		return nil, fmt.Errorf("ga4mp: invalid cart: %w", err)
+	}
+	steps := []struct {
+		name  string
+		extra map[string]interface{}
+	}{
+		{EventViewItem, nil},
+		{EventAddToCart, nil},
+		{EventBeginCheckout, map[string]interface{}{"coupon": cart.Coupon}},
+		{EventAddPaymentInfo, map[string]interface{}{"coupon": cart.Coupon, "payment_type": cart.PaymentType}},
+		{EventPurchase, map[string]interface{}{
+			"coupon":         cart.Coupon,
+			"transaction_id": cart.TransactionID,
+			"tax":            cart.Tax,
+			"shipping":       cart.Shipping,
+		}},
+	}
+	events := make([]Event, len(steps))
+	for i, s := range steps {
+		items := make([]Item, len(cart.Items))
+		copy(items, cart.Items)
+		params := map[string]interface{}{
+			"currency": cart.Currency,
+			"value":    cart.Value,
+			"items":    items,
+		}
+		for k, v := range s.extra {
+			if v != "" {
+				params[k] = v
+			}
+		}
+		events[i] = Event{Name: s.name, Params: params}
+	}
+	return events, nil
+}
@@ -3,14 +3,22 @@ package ga4mp
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 const (
@@ -18,77 +26,777 @@ const (
 	DebugEndpoint   = "https://www.google-analytics.com/debug/mp/collect"
 )
 
+var (
+	// ErrNotDebug is returned by Debug on a client not created with ClientOptions.Debug
+	ErrNotDebug = errors.New("ga4mp: client not configured for debug")
+	// ErrClosed is returned when sending through a closed Client or Batcher
+	ErrClosed = errors.New("ga4mp: closed")
+	// ErrPayloadTooLarge and ErrTooManyEvents are wrapped in a *LimitError
+	// for requests that need to be split, see SendSplit
+	ErrPayloadTooLarge = errors.New("ga4mp: payload exceeds 130kb")
+	ErrTooManyEvents   = errors.New("ga4mp: request exceeds 25 events")
+)
+
+// LimitError is a request over a size limit
+type LimitError struct {
+	// ErrPayloadTooLarge or ErrTooManyEvents
+	Err error
+	// Bytes or events of the request
+	Size int
+}
+
+// Error leaves out the prefix of Err, callers add their own context
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d", strings.TrimPrefix(e.Err.Error(), "ga4mp: "), e.Size)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
 type ClientOptions struct {
 	// Required: Admin > Data Streams > choose your stream > Measurement Protocol > Create
 	ApiSecret string
-	// Required: Admin > Data Streams > choose your stream > Measurement ID
+	// Required: Admin > Data Streams > choose your stream > Measurement ID.
+	// Sends fail with a descriptive error if it or ApiSecret is malformed.
 	MeasurementID string
-	// Perform client side validation fo the request before sending it
+	// For app streams, used instead of MeasurementID:
+	// Admin > Data Streams > choose your stream > Firebase App ID
+	FirebaseAppID string
+	// Perform client side validation fo the request before sending it,
+	// linting names and timestamps.
+	// Documented size limits are always checked.
 	Validate bool
 	// HTTP Client for sending requests
 	// defaults to http.DefaultClient if unset
 	HttpClient *http.Client
+	// Target the validation server instead of collect.
+	// Debug may only be called on a debug client,
+	// so a production client can't silently send events that are never recorded.
+	Debug bool
+	// Set Request.TimestampMicros to the time of sending if it is unset,
+	// the caller's Request is not modified
+	AutoTimestamp bool
+	// Set engagement_time_msec to 1 on events that don't have it,
+	// so server side events count towards active users and realtime reports.
+	// This inflates average engagement time and creates sessions for server only activity.
+	DefaultEngagementTime bool
+	// Called with non fatal problems found while sending,
+	// such as redacted values. May be nil.
+	Warn func(msg string)
+	// Redact or reject values that look like personal information,
+	// nil disables scrubbing.
+	Scrubber *Scrubber
+	// Reject or report user ids that look like personal information,
+	// nil disables the check
+	UserIDPolicy *UserIDPolicy
+	// Fail fast with ErrCircuitOpen after this many consecutive
+	// network errors or 429/5xx responses, 0 disables the breaker.
+	// Requests cancelled by the caller don't count, deadlines passing while waiting do.
+	BreakerThreshold int
+	// How long the breaker stays open before probing with a single request,
+	// defaults to 30s
+	BreakerCooldown time.Duration
+	// Collect endpoint, such as a server side tagging container,
+	// defaults to CollectEndpoint
+	Endpoint string
+	// Sign the final request body, compressed if it is,
+	// the returned header is added to the request.
+	// Used by custom endpoints that verify where requests come from.
+	Sign func(body []byte) (headerName, headerValue string)
+	// Maximum length of string event parameter values,
+	// for enforcing stricter local policies. Defaults to 100.
+	MaxParamLength int
+	// Maximum lengths of event, parameter and user property names,
+	// for endpoints with other constraints than GA4. Default to 40, 40 and 24.
+	MaxEventNameLength        int
+	MaxParamNameLength        int
+	MaxUserPropertyNameLength int
+	// Params added to every event that doesn't set them,
+	// such as page_location and page_referrer for web server events
+	DefaultParams map[string]interface{}
+	// Value of the traffic_type param set on every event, such as "internal",
+	// replacing any value of the event,
+	// for excluding dev and staging events with a GA4 internal traffic filter
+	TrafficType string
+	// Clock for timestamps, validation, and the circuit breaker,
+	// defaults to time.Now
+	Now func() time.Time
+	// Warn once more distinct event names than this have been sent,
+	// usually caused by names containing ids or other unbounded data.
+	// Properties are limited to 500 custom event names. 0 disables tracking.
+	MaxDistinctEvents int
+	// Add a param with this name to every event,
+	// to find specific test hits in reports and exports.
+	// It is subject to the usual name validation.
+	DebugCorrelationParam string
+	// Value of DebugCorrelationParam,
+	// defaults to a random id per request
+	DebugCorrelationID string
+	// Reject events and params not in the tracking plan
+	Schema *Schema
+	// Remove event params with reserved names or prefixes,
+	// reporting them through Warn, instead of failing validation
+	DropReservedParams bool
+	// Send bool event params as the integers 1 and 0.
+	// GA4 accepts booleans as they are, but their reporting treatment differs from numbers,
+	// set this for custom dimensions, metrics or exports that expect 0 and 1.
+	// A Schema then sees the integers, declare those params as ParamInteger.
+	BoolsAsInts bool
+	// Remove newlines, tabs and other control characters from string event params
+	// and user property values, which validation rejects
+	// as they break BigQuery exports and log parsing
+	StripControlChars bool
+	// Called for each event of a request after the collect endpoint accepted it,
+	// as sent, with the client's rewrites, such as Scrubber redactions,
+	// with the client id (or app instance id) and the response status code.
+	// Not called for Debug clients, as the debug endpoint records nothing.
+	// Called from the sending goroutine, for example to keep an audit log. May be nil.
+	OnEventSent func(clientID string, e Event, status int)
+	// Called with a copy of each request after the other options modified it,
+	// right before encoding and validation, to set fields computed at send time.
+	// May be nil.
+	BeforeMarshal func(r *Request)
+	// Maximum number of requests in flight for SendMany, SendSplit, DebugAll and DebugReader,
+	// defaults to 4, negative sends one at a time
+	Concurrency int
+	// Content-Type header of requests, defaults to application/json.
+	// Some proxies and server side tagging setups want application/json; charset=utf-8.
+	ContentType string
+	// Timeout of each Debug call, including those made by DebugAll and DebugReader,
+	// independent of the timeout of sends. 0 leaves it to ctx and the HttpClient.
+	DebugTimeout time.Duration
+	// Param names registered for custom dimensions and metrics in the property.
+	// Params that differ from one only by case or underscores are rejected,
+	// as the dimension would stay empty.
+	CustomDimensions []string
+	// When the collect endpoint rejects a request with a 4xx status other than 429,
+	// send it again to the debug endpoint and attach the result to the *APIError,
+	// to see why it was rejected at the cost of an extra request
+	DiagnoseFailures bool
+	// Report the Request.Warnings of every request through Warn when sending,
+	// without failing the send
+	LintOnSend bool
+	// Gzip request bodies larger than CompressThreshold,
+	// smaller ones are sent as plain JSON, as compressing them costs more than it saves
+	Compress bool
+	// Minimum body size to compress in bytes, defaults to 1024
+	CompressThreshold int
 }
 
 type Client struct {
+	mu       sync.RWMutex
 	query    string
-	validate bool
-	http     *http.Client
+	credErr  error
+	idParam  string
+	closed   bool
+	batchers map[*Batcher]struct{}
+
+	validate      bool
+	debug         bool
+	autoTimestamp bool
+	engagement    bool
+	scrubber      *Scrubber
+	warnf         func(string)
+	breaker       *breaker
+	endpoint      string
+	sign          func([]byte) (string, string)
+	limits        limits
+	defaultParams map[string]interface{}
+	trafficType   string
+	now           func() time.Time
+	names         *nameTracker
+	corrParam     string
+	corrID        string
+	schema        *Schema
+	dropReserved  bool
+	boolsAsInts   bool
+	stripControl  bool
+	onSent        func(string, Event, int)
+	beforeMarshal func(*Request)
+	concurrency   int
+	contentType   string
+	debugTimeout  time.Duration
+	dimensions    dimensions
+	counters      *counters
+	diagnose      bool
+	lint          bool
+	userIDPolicy  *UserIDPolicy
+	compressAbove int // 0 disables compression
+	http          *http.Client
 }
 
 func New(o ClientOptions) *Client {
-	v := make(url.Values)
-	v.Set("api_secret", o.ApiSecret)
-	v.Set("measurement_id", o.MeasurementID)
-
 	if o.HttpClient == nil {
 		o.HttpClient = http.DefaultClient
 	}
+	if o.Endpoint == "" {
+		o.Endpoint = CollectEndpoint
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	if o.ContentType == "" {
+		o.ContentType = "application/json"
+	}
+
+	idParam, id := "measurement_id", o.MeasurementID
+	if o.FirebaseAppID != "" {
+		idParam, id = "firebase_app_id", o.FirebaseAppID
+	}
+
+	c := &Client{
+		query:         credentialQuery(idParam, id, o.ApiSecret),
+		credErr:       checkCredentials(idParam, id, o.ApiSecret),
+		idParam:       idParam,
+		validate:      o.Validate,
+		debug:         o.Debug,
+		autoTimestamp: o.AutoTimestamp,
+		engagement:    o.DefaultEngagementTime,
+		scrubber:      o.Scrubber,
+		warnf:         o.Warn,
+		endpoint:      o.Endpoint,
+		sign:          o.Sign,
+		limits:        defaultLimits,
+		defaultParams: o.DefaultParams,
+		trafficType:   o.TrafficType,
+		now:           o.Now,
+		corrParam:     o.DebugCorrelationParam,
+		corrID:        o.DebugCorrelationID,
+		schema:        o.Schema,
+		dropReserved:  o.DropReservedParams,
+		boolsAsInts:   o.BoolsAsInts,
+		stripControl:  o.StripControlChars,
+		onSent:        o.OnEventSent,
+		beforeMarshal: o.BeforeMarshal,
+		concurrency:   o.Concurrency,
+		contentType:   o.ContentType,
+		debugTimeout:  o.DebugTimeout,
+		dimensions:    newDimensions(o.CustomDimensions),
+		counters:      &counters{},
+		diagnose:      o.DiagnoseFailures,
+		lint:          o.LintOnSend,
+		userIDPolicy:  o.UserIDPolicy,
+		http:          o.HttpClient,
+	}
+	if o.Compress {
+		c.compressAbove = o.CompressThreshold
+		if c.compressAbove <= 0 {
+			c.compressAbove = 1024
+		}
+	}
+	if c.concurrency == 0 {
+		c.concurrency = 4
+	} else if c.concurrency < 0 {
+		c.concurrency = 1
+	}
+	if o.MaxDistinctEvents > 0 {
+		c.names = &nameTracker{max: o.MaxDistinctEvents, seen: make(map[string]struct{})}
+	}
+	if o.MaxParamLength > 0 {
+		c.limits.paramValueLen = o.MaxParamLength
+	}
+	if o.MaxEventNameLength > 0 {
+		c.limits.eventNameLen = o.MaxEventNameLength
+	}
+	if o.MaxParamNameLength > 0 {
+		c.limits.paramNameLen = o.MaxParamNameLength
+	}
+	if o.MaxUserPropertyNameLength > 0 {
+		c.limits.userPropertyNameLen = o.MaxUserPropertyNameLength
+	}
+	if o.BreakerThreshold > 0 {
+		if o.BreakerCooldown <= 0 {
+			o.BreakerCooldown = 30 * time.Second
+		}
+		c.breaker = &breaker{threshold: o.BreakerThreshold, cooldown: o.BreakerCooldown}
+	}
+	return c
+}
+
+// SetCredentials replaces the measurement id
+// (firebase app id for app clients) and api secret,
+// safe to call concurrently with sending.
+// Each request uses either the old or the new credentials.
+// Malformed credentials are rejected, keeping the old ones.
+func (c *Client) SetCredentials(measurementID, apiSecret string) error {
+	if err := checkCredentials(c.idParam, measurementID, apiSecret); err != nil {
+		return err
+	}
+	q := credentialQuery(c.idParam, measurementID, apiSecret)
+	c.mu.Lock()
+	c.query = q
+	c.credErr = nil
+	c.mu.Unlock()
+	return nil
+}
+
+var measurementIDRe = regexp.MustCompile(`^G-[A-Z0-9]+$`)
+
+// checkCredentials catches ids and secrets mangled by copy and paste,
+// that the API would reject with an opaque error.
+// Unset values are allowed, for endpoints that add the credentials themselves.
+func checkCredentials(idParam, id, apiSecret string) error {
+	if idParam == "measurement_id" && id != "" && !measurementIDRe.MatchString(id) {
+		return fmt.Errorf("ga4mp: measurement id %q doesn't match G-XXXXXXXXXX", id)
+	}
+	if idParam == "firebase_app_id" && strings.IndexFunc(id, badCredentialRune) >= 0 {
+		return fmt.Errorf("ga4mp: firebase app id %q has whitespace or control characters", id)
+	}
+	if strings.IndexFunc(apiSecret, badCredentialRune) >= 0 {
+		return fmt.Errorf("ga4mp: api secret has whitespace or control characters")
+	}
+	return nil
+}
+
+func badCredentialRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+func credentialQuery(idParam, id, apiSecret string) string {
+	v := make(url.Values)
+	v.Set("api_secret", apiSecret)
+	v.Set(idParam, id)
+	return v.Encode()
+}
+
+// requestURL is url with the credential overrides of r
+func (c *Client) requestURL(endpoint string, r *Request) (string, error) {
+	if r.MeasurementID == "" && r.ApiSecret == "" {
+		return c.url(endpoint)
+	}
+	if err := checkCredentials(c.idParam, r.MeasurementID, r.ApiSecret); err != nil {
+		return "", err
+	}
+	c.mu.RLock()
+	q, err := url.ParseQuery(c.query)
+	credErr := c.credErr
+	c.mu.RUnlock()
+	if credErr != nil && (r.MeasurementID == "" || r.ApiSecret == "") {
+		return "", credErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("ga4mp: parse credentials: %w", err)
+	}
+	if r.MeasurementID != "" {
+		q.Set(c.idParam, r.MeasurementID)
+	}
+	if r.ApiSecret != "" {
+		q.Set("api_secret", r.ApiSecret)
+	}
+	return endpoint + "?" + q.Encode(), nil
+}
+
+// url returns endpoint with the credentials,
+// or the error that made New reject them
+func (c *Client) url(endpoint string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return endpoint + "?" + c.query, c.credErr
+}
+
+// Shutdown flushes and closes the Batchers created for c,
+// and makes further sends return ErrClosed.
+// If ctx is done before the flush completes, in flight sends are aborted,
+// and the events of all Batchers that weren't sent are reported in a *FlushError.
+// Calling it again has no effect.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	batchers := c.batchers
+	c.batchers = nil
+	c.mu.Unlock()
+
+	var lost *FlushError
+	for b := range batchers {
+		var fe *FlushError
+		if err := b.Close(ctx); errors.As(err, &fe) {
+			if lost == nil {
+				lost = &FlushError{Err: fe.Err}
+			}
+			lost.Lost += fe.Lost
+		}
+	}
+	if lost != nil {
+		return lost
+	}
+	return nil
+}
+
+// Close implements io.Closer by calling Shutdown without a deadline
+func (c *Client) Close() error {
+	return c.Shutdown(context.Background())
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closed
+}
+
+// SafeURL returns the URL requests are sent to with the api secret redacted,
+// for logging
+func (c *Client) SafeURL() string {
+	raw, _ := c.url(c.Endpoint())
+	u, err := url.Parse(raw)
+	if err != nil {
+		return c.Endpoint()
+	}
+	return redactURL(u)
+}
+
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	q.Del("api_secret")
+	uu := *u
+	uu.RawQuery = "api_secret=***"
+	if rest := q.Encode(); rest != "" {
+		uu.RawQuery += "&" + rest
+	}
+	return uu.String()
+}
 
-	return &Client{
-		query:    v.Encode(),
-		validate: o.Validate,
-		http:     o.HttpClient,
+// Endpoint returns the endpoint the client sends requests to
+func (c *Client) Endpoint() string {
+	if c.debug {
+		return DebugEndpoint
 	}
+	return c.endpoint
+}
+
+// Sender sends requests, implemented by Client,
+// and by ga4mptest.MemorySink for tests and local development
+type Sender interface {
+	Send(ctx context.Context, r *Request) error
 }
 
+// Debugger validates requests against the debug endpoint, implemented by Client
+type Debugger interface {
+	Debug(ctx context.Context, r *Request) (ValidationResponse, error)
+}
+
+var (
+	_ Sender   = (*Client)(nil)
+	_ Debugger = (*Client)(nil)
+)
+
 func (c *Client) Send(ctx context.Context, r *Request) error {
-	req, err := c.prepareRequest(ctx, r, CollectEndpoint+"?"+c.query)
+	_, err := c.send(ctx, r)
+	return err
+}
+
+// SendResult is the outcome of SendWithResponse
+type SendResult struct {
+	StatusCode int
+	// Request.Warnings of the request
+	Warnings []string
+	// The collect endpoint accepted a request that Warnings says records nothing,
+	// such as one without events or with a stale timestamp
+	NoEffect bool
+}
+
+// SendWithResponse is Send, also reporting the status code
+// and cross checking an accepted request against Request.Warnings,
+// as a 2xx response from collect means received, not recorded
+func (c *Client) SendWithResponse(ctx context.Context, r *Request) (SendResult, error) {
+	if c.isClosed() {
+		return SendResult{}, ErrClosed
+	}
+	code, sent, err := c.deliver(ctx, r)
+	res := SendResult{StatusCode: code}
 	if err != nil {
-		return err
+		return res, err
 	}
-	res, err := c.http.Do(req)
+	// the request as sent, with the client's rewrites
+	if d, err := sent.decodeRawEvents(); err == nil {
+		sent = d
+	}
+	now := c.now()
+	res.Warnings = sent.warnings(now)
+	res.NoEffect = sent.noEffect(now)
+	return res, nil
+}
+
+// send sends r, returning the response status code if there was a response
+func (c *Client) send(ctx context.Context, r *Request) (int, error) {
+	if c.isClosed() {
+		return 0, ErrClosed
+	}
+	code, _, err := c.deliver(ctx, r)
+	return code, err
+}
+
+// deliver is send for a closing client,
+// also returning the request as sent, see prepareRequest
+func (c *Client) deliver(ctx context.Context, r *Request) (int, *Request, error) {
+	code, sent, err := c.post(ctx, r)
+	if !c.debug {
+		c.counters.record(len(r.Events), err)
+	}
+	return code, sent, err
+}
+
+func (c *Client) post(ctx context.Context, r *Request) (int, *Request, error) {
+	u, err := c.requestURL(c.Endpoint(), r)
 	if err != nil {
-		return fmt.Errorf("ga4mp: post: %w", err)
+		return 0, nil, err
 	}
-	defer res.Body.Close()
+	req, sent, err := c.prepareRequest(ctx, r, u, c.debug)
+	if err != nil {
+		return 0, nil, err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer drain(res.Body)
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		b, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("ga4mp: %v: %q", res.Status, string(b))
+		e := newAPIError(res)
+		if c.diagnose && res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+			if msg, err := c.debugRequest(ctx, r); err == nil {
+				e.Validation = &msg
+			}
+		}
+		return res.StatusCode, nil, e
 	}
-	return nil
+	if c.onSent != nil && !c.debug {
+		id := sent.ClientID
+		if id == "" {
+			id = sent.AppInstanceID
+		}
+		events := sent.Events
+		if d, err := sent.decodeRawEvents(); err == nil {
+			events = d.Events
+		}
+		for _, e := range events {
+			c.onSent(id, e, res.StatusCode)
+		}
+	}
+	return res.StatusCode, sent, nil
+}
+
+// APIError is a non 2xx response from the collect or debug endpoint
+type APIError struct {
+	StatusCode int
+	Status     string
+	// The start of the response body
+	Body string
+	// The debug endpoint's validation of the rejected request,
+	// set with ClientOptions.DiagnoseFailures
+	Validation *ValidationResponse
+}
+
+func (e *APIError) Error() string {
+	if e.Validation != nil {
+		if errs := e.Validation.Errors(); len(errs) > 0 {
+			return fmt.Sprintf("ga4mp: %v: %q: %s: %s", e.Status, e.Body, errs[0].FieldPath, errs[0].Description)
+		}
+	}
+	return fmt.Sprintf("ga4mp: %v: %q", e.Status, e.Body)
+}
+
+func newAPIError(res *http.Response) *APIError {
+	e := &APIError{StatusCode: res.StatusCode, Status: res.Status}
+	if body, err := responseBody(res); err == nil {
+		b, _ := io.ReadAll(io.LimitReader(body, 4<<10))
+		e.Body = string(b)
+	}
+	return e
+}
+
+// drain reads what is left of a response body before closing it,
+// so the transport can reuse the connection
+func drain(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
+
+// SendEvents sends events in a single request for clientID
+func (c *Client) SendEvents(ctx context.Context, clientID string, events ...Event) error {
+	return c.Send(ctx, &Request{ClientID: clientID, Events: events})
+}
+
+// UserClient sends events for a single client id through a shared Client
+type UserClient struct {
+	c        *Client
+	clientID string
+}
+
+// ClientFor returns a UserClient bound to clientID,
+// c is not modified.
+func (c *Client) ClientFor(clientID string) UserClient {
+	return UserClient{c: c, clientID: clientID}
+}
+
+// Send sends events in a single request for the bound client id
+func (u UserClient) Send(ctx context.Context, events ...Event) error {
+	return u.c.SendEvents(ctx, u.clientID, events...)
+}
+
+// do sends req through the circuit breaker if configured
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	var probe bool
+	if c.breaker != nil {
+		var ok bool
+		if ok, probe = c.breaker.allow(c.now()); !ok {
+			return nil, ErrCircuitOpen
+		}
+	}
+	// a context done before sending says nothing about the endpoint
+	expired := req.Context().Err() != nil
+	res, err := c.http.Do(req)
+	if c.breaker != nil {
+		if expired || errors.Is(req.Context().Err(), context.Canceled) {
+			// the caller gave up, unlike a deadline passing while the endpoint hangs
+			c.breaker.release(probe)
+		} else {
+			ok := err == nil && res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500
+			c.breaker.record(probe, ok, c.now())
+		}
+	}
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			ue.URL = redactURL(req.URL)
+		}
+		return nil, fmt.Errorf("ga4mp: post: %w", err)
+	}
+	return res, nil
+}
+
+// responseBody returns the decoded body of res.
+// The transport only decodes responses it asked to be compressed,
+// proxies may gzip them regardless.
+func responseBody(res *http.Response) (io.Reader, error) {
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return res.Body, nil
+	}
+	return gzip.NewReader(res.Body)
+}
+
+// SendAsync calls Send in a new goroutine.
+// The result is delivered on the returned channel, which is then closed.
+// The channel is buffered so the goroutine exits even if it is never read,
+// cancelling ctx aborts the in flight request.
+func (c *Client) SendAsync(ctx context.Context, r *Request) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		ch <- c.Send(ctx, r)
+	}()
+	return ch
 }
 
 func (c *Client) Debug(ctx context.Context, r *Request) (ValidationResponse, error) {
+	if !c.debug {
+		return ValidationResponse{}, ErrNotDebug
+	}
+	return c.debugRequest(ctx, r)
+}
+
+// SendAndValidate validates r with the debug endpoint,
+// and sends it to the collect endpoint if there are no validation errors,
+// failing with the first one otherwise.
+// It doubles the requests made, and is meant for integration tests and QA, not high volume sending.
+// The client doesn't need ClientOptions.Debug, with it set both requests go to the debug endpoint.
+func (c *Client) SendAndValidate(ctx context.Context, r *Request) (ValidationResponse, error) {
+	msg, err := c.debugRequest(ctx, r)
+	if err != nil {
+		return msg, err
+	}
+	if errs := msg.Errors(); len(errs) > 0 {
+		return msg, fmt.Errorf("ga4mp: validation failed: %s: %s", errs[0].FieldPath, errs[0].Description)
+	}
+	return msg, c.Send(ctx, r)
+}
+
+// debugRequest is Debug for clients not configured for debug
+func (c *Client) debugRequest(ctx context.Context, r *Request) (ValidationResponse, error) {
 	var msg ValidationResponse
+	if c.isClosed() {
+		return msg, ErrClosed
+	}
+	if c.debugTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.debugTimeout)
+		defer cancel()
+	}
 
-	req, err := c.prepareRequest(ctx, r, DebugEndpoint+"?"+c.query)
+	u, err := c.requestURL(DebugEndpoint, r)
 	if err != nil {
 		return msg, err
 	}
-	res, err := c.http.Do(req)
+	req, _, err := c.prepareRequest(ctx, r, u, true)
+	if err != nil {
+		return msg, err
+	}
+	res, err := c.do(req)
 	if err != nil {
-		return msg, fmt.Errorf("ga4mp: post: %w", err)
+		return msg, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		// error pages are HTML or plain text, not validation messages
+		return msg, newAPIError(res)
+	}
 
-	err = json.NewDecoder(res.Body).Decode(&msg)
+	body, err := responseBody(res)
+	if err != nil {
+		return msg, fmt.Errorf("ga4mp: read validation response: %w", err)
+	}
+	err = json.NewDecoder(body).Decode(&msg)
 	if err != nil {
 		return msg, fmt.Errorf("ga4mp: parse validaion response: %w", err)
 	}
 	return msg, nil
 }
 
+// DebugAll calls Debug for each of rs, at most ClientOptions.Concurrency at a time.
+// Results are indexed like rs,
+// requests not started before ctx is done report ctx.Err().
+func (c *Client) DebugAll(ctx context.Context, rs []*Request) ([]ValidationResponse, []error) {
+	msgs := make([]ValidationResponse, len(rs))
+	errs := make([]error, len(rs))
+	parallel(ctx, len(rs), c.concurrency, func(i int) {
+		msgs[i], errs[i] = c.Debug(ctx, rs[i])
+	}, func(i int) {
+		errs[i] = ctx.Err()
+	})
+	return msgs, errs
+}
+
+// parallel calls f for 0 <= i < n with at most limit calls running at once.
+// Once ctx is done, skip is called for the indices not yet started.
+func parallel(ctx context.Context, n, limit int, f, skip func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			for ; i < n; i++ {
+				skip(i)
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
 type ValidationResponse struct {
 	ValidationMessages []ValidationMessage `json:"validationMessages"`
 }
@@ -99,102 +807,813 @@ type ValidationMessage struct {
 	ValidationCode string `json:"validationCode"`
 }
 
-func (c *Client) prepareRequest(ctx context.Context, r *Request, url string) (*http.Request, error) {
-	b, err := json.Marshal(r)
+// Severity classifies a ValidationMessage
+type Severity int
+
+const (
+	// The request would be rejected or the offending data dropped
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Severity derives the severity from the validation code.
+// All documented codes are errors, unknown codes are errors
+// unless they mark themselves as informational or a warning.
+func (m ValidationMessage) Severity() Severity {
+	switch {
+	case strings.Contains(m.ValidationCode, "INFO"):
+		return SeverityInfo
+	case strings.Contains(m.ValidationCode, "WARN"):
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// Errors returns the messages with SeverityError
+func (r ValidationResponse) Errors() []ValidationMessage {
+	var msgs []ValidationMessage
+	for _, m := range r.ValidationMessages {
+		if m.Severity() == SeverityError {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// HasErrors reports whether any message has SeverityError
+func (r ValidationResponse) HasErrors() bool {
+	for _, m := range r.ValidationMessages {
+		if m.Severity() == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ByField groups the messages by their FieldPath
+func (r ValidationResponse) ByField() map[string][]ValidationMessage {
+	fields := make(map[string][]ValidationMessage)
+	for _, m := range r.ValidationMessages {
+		fields[m.FieldPath] = append(fields[m.FieldPath], m)
+	}
+	return fields
+}
+
+// prepareRequest returns the http request sending r,
+// and r as sent, with the client's rewrites and any raw events decoded
+// when the client checks them. debug is set for requests to the debug endpoint.
+func (c *Client) prepareRequest(ctx context.Context, r *Request, rawURL string, debug bool) (*http.Request, *Request, error) {
+	r, err := c.rewrite(r, debug)
 	if err != nil {
-		return nil, fmt.Errorf("ga4mp: marshal request: %w", err)
+		return nil, nil, err
+	}
+
+	b, err := encodeRequest(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ga4mp: marshal request: %w", err)
+	}
+	if err := r.checkLimits(len(b), c.limits); err != nil {
+		return nil, nil, fmt.Errorf("ga4mp: check limits: %w", err)
+	}
+	// the request with any raw events decoded, when checking it
+	checked := r
+	if r.rawEvents != nil && (c.validate || c.schema != nil || len(c.dimensions) > 0 || c.lint || c.names != nil) {
+		if checked, err = r.decodeRawEvents(); err != nil {
+			return nil, nil, fmt.Errorf("ga4mp: validate request: %w", err)
+		}
+		if err := checked.checkLimits(len(b), c.limits); err != nil {
+			return nil, nil, fmt.Errorf("ga4mp: check limits: %w", err)
+		}
 	}
 	if c.validate {
-		err := r.validate()
+		err := checked.validate(c.now())
 		if err != nil {
-			return nil, fmt.Errorf("ga4mp: validate request: %w", err)
+			return nil, nil, fmt.Errorf("ga4mp: validate request: %w", err)
+		}
+	}
+	if c.schema != nil {
+		if err := c.schema.validate(checked); err != nil {
+			return nil, nil, fmt.Errorf("ga4mp: validate request: %w", err)
+		}
+	}
+	if len(c.dimensions) > 0 {
+		if err := c.dimensions.validate(checked); err != nil {
+			return nil, nil, fmt.Errorf("ga4mp: validate request: %w", err)
+		}
+	}
+
+	if c.userIDPolicy != nil {
+		if err := c.userIDPolicy.check(r, c.warn); err != nil {
+			return nil, nil, fmt.Errorf("ga4mp: validate request: %w", err)
 		}
-		if len(b) > 130000 {
-			return nil, fmt.Errorf("ga4mp: payload exceeds 130kb: %d", len(b))
+	}
+	if c.lint {
+		for _, w := range checked.warnings(c.now()) {
+			c.warn("ga4mp: %s", w)
 		}
 	}
+	if c.names != nil && c.names.track(checked.Events) {
+		c.warn("ga4mp: sent more than %d distinct event names, check names don't contain unbounded data", c.names.max)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	gzipped := c.compressAbove > 0 && len(b) > c.compressAbove
+	if gzipped {
+		if b, err = compress(b); err != nil {
+			return nil, nil, fmt.Errorf("ga4mp: compress request: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(b))
 	if err != nil {
-		return nil, fmt.Errorf("ga4mp: prepare request: %w", err)
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			// a malformed endpoint, the url can't be parsed to redact only the secret
+			if i := strings.IndexByte(ue.URL, '?'); i >= 0 {
+				ue.URL = ue.URL[:i] + "?api_secret=***"
+			}
+		}
+		return nil, nil, fmt.Errorf("ga4mp: prepare request: %w", err)
+	}
+	req.Header.Set("content-type", c.contentType)
+	if gzipped {
+		req.Header.Set("content-encoding", "gzip")
+	}
+	if c.sign != nil {
+		req.Header.Set(c.sign(b))
 	}
-	req.Header.Set("content-type", "application/json")
 
-	return req, nil
+	return req, checked, nil
+}
+
+func compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshal encodes v as JSON without escaping HTML characters,
+// keeping payloads byte for byte comparable with their source strings
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// encodeRequest marshals r, using the cached encoding of frozen events,
+// and merging r.Extra into the top level object
+func encodeRequest(r *Request) ([]byte, error) {
+	b, err := encodeFields(r)
+	if err != nil || len(r.Extra) == 0 {
+		return b, err
+	}
+	for k := range r.Extra {
+		if _, ok := requestFields[k]; ok {
+			return nil, fmt.Errorf("extra field %q collides with a Request field", k)
+		}
+	}
+	extra, err := marshal(r.Extra)
+	if err != nil {
+		return nil, err
+	}
+	// both are objects with at least one field: {...,...}
+	b = append(b[:len(b)-1], ',')
+	return append(b, extra[1:]...), nil
+}
+
+// top level fields modeled by Request
+var requestFields = map[string]struct{}{
+	"client_id":            {},
+	"app_instance_id":      {},
+	"user_id":              {},
+	"timestamp_micros":     {},
+	"user_properties":      {},
+	"non_personalized_ads": {},
+	"consent":              {},
+	"events":               {},
+	"user_location":        {},
+	"device":               {},
+	"ip_override":          {},
+	"user_agent":           {},
+}
+
+// MarshalJSON encodes the fields of r that are sent, with its raw events and Extra fields.
+// json.Marshal then escapes &, < and > in strings, which the sent body keeps as they are,
+// Size reports the length of the sent body, and MarshalRequest encodes it without escaping.
+func (r Request) MarshalJSON() ([]byte, error) {
+	return encodeRequest(&r)
+}
+
+// plainRequest is a Request without its MarshalJSON method
+type plainRequest Request
+
+func encodeFields(r *Request) ([]byte, error) {
+	if r.rawEvents != nil {
+		return marshal(struct {
+			*plainRequest
+			Events json.RawMessage `json:"events"`
+		}{(*plainRequest)(r), r.rawEvents})
+	}
+	var frozen bool
+	for _, e := range r.Events {
+		if e.raw != nil {
+			frozen = true
+			break
+		}
+	}
+	if !frozen {
+		return marshal((*plainRequest)(r))
+	}
+	events := make([]json.RawMessage, len(r.Events))
+	for i, e := range r.Events {
+		events[i] = e.raw
+		if e.raw == nil {
+			b, err := marshal(e)
+			if err != nil {
+				return nil, err
+			}
+			events[i] = b
+		}
+	}
+	return marshal(struct {
+		*plainRequest
+		Events []json.RawMessage `json:"events"`
+	}{(*plainRequest)(r), events})
+}
+
+// rewrite applies the client's send time options to a copy of r,
+// debug for requests to the debug endpoint
+func (c *Client) rewrite(r *Request, debug bool) (*Request, error) {
+	if !c.autoTimestamp && !c.engagement && c.scrubber == nil && len(c.defaultParams) == 0 && c.trafficType == "" && c.corrParam == "" && !c.dropReserved && !c.boolsAsInts && !c.stripControl && c.beforeMarshal == nil {
+		return r, nil
+	}
+	orig := r
+	r = r.clone()
+	if c.autoTimestamp && r.TimestampMicros == 0 {
+		r.TimestampMicros = c.now().UnixMicro()
+	}
+	for _, e := range r.Events {
+		for k, v := range c.defaultParams {
+			if _, ok := e.Params[k]; !ok {
+				e.Params[k] = v
+			}
+		}
+		if c.trafficType != "" {
+			e.Params["traffic_type"] = c.trafficType
+		}
+	}
+	if c.dropReserved {
+		for _, e := range r.Events {
+			for k := range e.Params {
+				if reservedParam(k) {
+					delete(e.Params, k)
+					c.warn("ga4mp: dropped reserved event %q parameter %q", e.Name, k)
+				}
+			}
+		}
+	}
+	if c.corrParam != "" {
+		id := c.corrID
+		if id == "" {
+			var b [8]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		for _, e := range r.Events {
+			e.Params[c.corrParam] = id
+		}
+	}
+	if c.engagement {
+		for _, e := range r.Events {
+			if _, ok := e.Params["engagement_time_msec"]; !ok {
+				e.Params["engagement_time_msec"] = 1
+			}
+		}
+	}
+	if c.boolsAsInts {
+		for _, e := range r.Events {
+			for k, v := range e.Params {
+				if b, ok := v.(bool); ok {
+					e.Params[k] = boolInt(b)
+				}
+			}
+		}
+	}
+	if c.stripControl {
+		for k, v := range r.UserProperties {
+			r.UserProperties[k] = stripControl(v)
+		}
+		for _, e := range r.Events {
+			for k, v := range e.Params {
+				if s, ok := v.(string); ok {
+					e.Params[k] = stripControl(s)
+				}
+			}
+		}
+	}
+	if c.scrubber != nil {
+		if err := c.scrubber.scrub(r, c.warn); err != nil {
+			return nil, fmt.Errorf("ga4mp: scrub request: %w", err)
+		}
+	}
+	if debug && (c.dropReserved || c.scrubber != nil) {
+		c.dropDiagnostics(orig, r)
+	}
+	if c.beforeMarshal != nil {
+		c.beforeMarshal(r)
+	}
+	return r, nil
+}
+
+// dropDiagnosticParam lists the params the client dropped or redacted from an event,
+// for QA validating requests with the debug endpoint
+const dropDiagnosticParam = "ga4mp_dropped"
+
+// dropDiagnostics sets dropDiagnosticParam on the events of r
+// that lost params of orig, only for requests to the debug endpoint,
+// so production data is unaffected
+func (c *Client) dropDiagnostics(orig, r *Request) {
+	for i, e := range r.Events {
+		var changed []string
+		for _, k := range sortedKeys(orig.Events[i].Params) {
+			v, ok := e.Params[k]
+			if !ok {
+				changed = append(changed, k)
+			} else if v == Redacted && orig.Events[i].Params[k] != Redacted {
+				changed = append(changed, k+" (redacted)")
+			}
+		}
+		if len(changed) == 0 || len(e.Params) >= maxEventParams {
+			continue
+		}
+		diag := strings.Join(changed, ",")
+		if len(diag) > c.limits.paramValueLen {
+			diag = diag[:c.limits.paramValueLen]
+		}
+		e.Params[dropDiagnosticParam] = diag
+	}
+}
+
+func stripControl(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// validText rejects newlines, tabs and other control characters in s
+func validText(s string) error {
+	if i := strings.IndexFunc(s, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("control character %U in %q", []rune(s[i:])[0], s)
+	}
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (c *Client) warn(format string, args ...interface{}) {
+	if c.warnf != nil {
+		c.warnf(fmt.Sprintf(format, args...))
+	}
 }
 
 type Request struct {
-	// Required: A unique ID per user/instance combination
-	ClientID string `json:"client_id"`
+	// A unique ID per user/instance combination for web streams,
+	// exactly one of ClientID or AppInstanceID is required
+	ClientID string `json:"client_id,omitempty"`
+	// The Firebase app instance id for app streams
+	AppInstanceID string `json:"app_instance_id,omitempty"`
 	// A unique cross platform ID for the user
 	UserID string `json:"user_id"`
-	// Backdate the event
-	TimestampMicros    int64             `json:"timestamp_micros"`
+	// Backdate the event, unset uses the time the request is received
+	TimestampMicros    int64             `json:"timestamp_micros,omitempty"`
 	UserProperties     map[string]string `json:"user_properties"`
 	NonPersonalizedAds bool              `json:"non_personalized_ads"`
 	Events             []Event           `json:"events"`
+	// Consent for ads use of the request, see ConsentState
+	Consent *Consent `json:"consent,omitempty"`
+	// Geographic information, overriding the location derived from the ip
+	UserLocation *UserLocation `json:"user_location,omitempty"`
+	// Device information, for server side events that have no client
+	Device *Device `json:"device,omitempty"`
+	// IPv4 or IPv6 address of the user, for geo lookup of server side events
+	IPOverride string `json:"ip_override,omitempty"`
+	// User agent of the user, for device and browser lookup of server side events.
+	// This is part of the payload, the User-Agent header of the request
+	// made by the HttpClient identifies the sender and is not used.
+	UserAgentOverride string `json:"user_agent,omitempty"`
+	// Top level fields this package doesn't model yet,
+	// merged into the JSON object when sending.
+	// Keys may not be the names of the fields above.
+	Extra map[string]interface{} `json:"-"`
+
+	// Send this request to another property than the client's,
+	// overriding ClientOptions.MeasurementID (FirebaseAppID for app clients)
+	// and ApiSecret when set
+	MeasurementID string `json:"-"`
+	ApiSecret     string `json:"-"`
+
+	// pre encoded events array set by SetRawEvents
+	rawEvents json.RawMessage
 }
 
-func (r Request) validate() error {
-	if len(r.ClientID) == 0 {
-		return fmt.Errorf("ClientID must be set")
+// SetRawEvents replaces Events with an already encoded JSON array of events,
+// that is sent as is.
+// It is only decoded to check limits and lint with ClientOptions.Validate,
+// and with Validate, also the only way it is checked by a Schema or CustomDimensions.
+// Client options that modify events don't apply to it,
+// and OnEventSent and Stats don't see its events.
+func (r *Request) SetRawEvents(events json.RawMessage) {
+	r.Events = nil
+	r.rawEvents = events
+}
+
+// decodeRawEvents returns r with the events set by SetRawEvents decoded into Events
+func (r *Request) decodeRawEvents() (*Request, error) {
+	if r.rawEvents == nil {
+		return r, nil
 	}
-	if d := time.Since(time.UnixMicro(r.TimestampMicros)); d > 3*72*time.Hour {
-		return fmt.Errorf("timestamp from longer than 3 days back: %v", d)
+	rr := *r
+	rr.rawEvents = nil
+	if err := json.Unmarshal(r.rawEvents, &rr.Events); err != nil {
+		return nil, fmt.Errorf("decode raw events: %w", err)
+	}
+	return &rr, nil
+}
+
+// Size returns the length of the JSON encoding of r,
+// which is limited to 130kb
+func (r *Request) Size() (int, error) {
+	b, err := encodeRequest(r)
+	return len(b), err
+}
+
+// clone copies r deep enough that event params can be modified
+func (r *Request) clone() *Request {
+	rr := *r
+	if r.UserProperties != nil {
+		rr.UserProperties = make(map[string]string, len(r.UserProperties))
+		for k, v := range r.UserProperties {
+			rr.UserProperties[k] = v
+		}
+	}
+	if r.Extra != nil {
+		rr.Extra = make(map[string]interface{}, len(r.Extra))
+		for k, v := range r.Extra {
+			rr.Extra[k] = v
+		}
+	}
+	rr.Events = make([]Event, len(r.Events))
+	for i, e := range r.Events {
+		rr.Events[i] = e.clone()
+	}
+	return &rr
+}
+
+// limits
+// https://developers.google.com/analytics/devguides/collection/protocol/ga4/sending-events?client_type=gtag#limitations
+const (
+	maxEvents               = 25
+	maxEventParams          = 25
+	maxUserProperties       = 25
+	maxEventNameLen         = 40
+	maxParamNameLen         = 40
+	maxParamValueLen        = 100
+	maxUserPropertyNameLen  = 24
+	maxUserPropertyValueLen = 36
+	maxPayloadBytes         = 130000
+	maxTimestampAge         = 72 * time.Hour
+	// allowed clock skew for timestamps in the future
+	maxTimestampSkew = 5 * time.Minute
+)
+
+// params with their own length limits
+// https://support.google.com/analytics/answer/9267744
+var paramValueLens = map[string]int{
+	"page_location": 1000,
+	"page_referrer": 420,
+	"page_title":    300,
+}
+
+// limits are the configurable limits
+type limits struct {
+	paramValueLen       int
+	eventNameLen        int
+	paramNameLen        int
+	userPropertyNameLen int
+}
+
+var defaultLimits = limits{
+	paramValueLen:       maxParamValueLen,
+	eventNameLen:        maxEventNameLen,
+	paramNameLen:        maxParamNameLen,
+	userPropertyNameLen: maxUserPropertyNameLen,
+}
+
+// checkLimits validates r against the documented limits.
+// size is the length of the marshaled request.
+func (r Request) checkLimits(size int, l limits) error {
+	if size > maxPayloadBytes {
+		return &LimitError{Err: ErrPayloadTooLarge, Size: size}
 	}
-	if len(r.UserProperties) > 25 {
-		return fmt.Errorf("request exceeds 25 user_properties: %d", len(r.UserProperties))
+	if len(r.UserProperties) > maxUserProperties {
+		return fmt.Errorf("request exceeds %d user_properties: %d", maxUserProperties, len(r.UserProperties))
 	}
 	for k, v := range r.UserProperties {
-		if err := validName(k, 24, reservedUserProperties, reservedUserPropertyPrefix); err != nil {
+		if err := nameLen(k, l.userPropertyNameLen); err != nil {
 			return fmt.Errorf("invalid user property name: %w", err)
 		}
-		if len(v) > 36 {
-			return fmt.Errorf("user property longer than 36: %q", v)
+		if len(v) > maxUserPropertyValueLen {
+			return fmt.Errorf("user property longer than %d: %q", maxUserPropertyValueLen, v)
 		}
 	}
+	if len(r.Events) > maxEvents {
+		return &LimitError{Err: ErrTooManyEvents, Size: len(r.Events)}
+	}
+	for i, e := range r.Events {
+		if err := e.checkLimits(l); err != nil {
+			return eventError(i, e, err)
+		}
+	}
+	return nil
+}
 
-	if len(r.Events) > 25 {
-		return fmt.Errorf("request exceeds 25 events: %d", len(r.Events))
+func (e Event) checkLimits(l limits) error {
+	if err := nameLen(e.Name, l.eventNameLen); err != nil {
+		return fmt.Errorf("invalid event name: %w", err)
 	}
+	if len(e.Params) > maxEventParams {
+		return fmt.Errorf("event exceeds %d params: %d", maxEventParams, len(e.Params))
+	}
+	for k, v := range e.Params {
+		if err := nameLen(k, l.paramNameLen); err != nil {
+			return fmt.Errorf("invalid parameter name: %w", err)
+		}
+		max, ok := paramValueLens[k]
+		if !ok {
+			max = l.paramValueLen
+		}
+		if vv, ok := v.(string); ok && len(vv) > max {
+			return fmt.Errorf("parameter longer than %d: %q", max, vv)
+		}
+	}
+	return nil
+}
+
+// eventError adds the index and name of the event at r.Events[i] to err
+func eventError(i int, e Event, err error) error {
+	return fmt.Errorf("events[%d] (%s): %w", i, e.Name, err)
+}
+
+// Validate runs the same checks as a Client with Validate set does before sending
+func (r Request) Validate() error {
+	b, err := encodeRequest(&r)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	rr, err := r.decodeRawEvents()
+	if err != nil {
+		return err
+	}
+	if err := rr.checkLimits(len(b), defaultLimits); err != nil {
+		return err
+	}
+	return rr.validate(time.Now())
+}
+
+// sharesTimestamp reports whether r.TimestampMicros applies to any event,
+// as events with a timestamp of their own override it
+func (r Request) sharesTimestamp() bool {
 	for _, e := range r.Events {
+		if e.TimestampMicros == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validate lints r, now is the current time
+func (r Request) validate(now time.Time) error {
+	if r.ClientID == "" && r.AppInstanceID == "" {
+		return fmt.Errorf("one of ClientID (client_id) or AppInstanceID (app_instance_id) must be set")
+	} else if r.ClientID != "" && r.AppInstanceID != "" {
+		return fmt.Errorf("only one of ClientID (client_id) or AppInstanceID (app_instance_id) may be set")
+	}
+	if r.TimestampMicros != 0 && r.sharesTimestamp() {
+		if err := validTimestamp(r.TimestampMicros, now); err != nil {
+			return err
+		}
+	}
+	if r.Consent != nil {
+		if err := r.Consent.validate(r.NonPersonalizedAds); err != nil {
+			return fmt.Errorf("invalid consent: %w", err)
+		}
+	}
+	if r.UserLocation != nil {
+		if err := r.UserLocation.validate(); err != nil {
+			return fmt.Errorf("invalid user_location: %w", err)
+		}
+	}
+	if r.Device != nil {
+		if err := r.Device.validate(); err != nil {
+			return fmt.Errorf("invalid device: %w", err)
+		}
+	}
+	if r.IPOverride != "" && net.ParseIP(r.IPOverride) == nil {
+		return fmt.Errorf("ip_override not an ip address: %q", r.IPOverride)
+	}
+	for k := range r.UserProperties {
+		if err := validName(k, reservedUserProperties, reservedUserPropertyPrefix); err != nil {
+			return fmt.Errorf("invalid user property name: %w", err)
+		}
+		if err := validText(r.UserProperties[k]); err != nil {
+			return fmt.Errorf("invalid user property %q: %w", k, err)
+		}
+	}
+	for i, e := range r.Events {
 		err := e.validate()
 		if err != nil {
-			return err
+			return eventError(i, e, err)
+		}
+		if e.TimestampMicros != 0 {
+			if err := validTimestamp(e.TimestampMicros, now); err != nil {
+				return eventError(i, e, err)
+			}
 		}
 	}
 	return nil
 }
 
+func validTimestamp(micros int64, now time.Time) error {
+	d := now.Sub(time.UnixMicro(micros))
+	if d > maxTimestampAge {
+		return fmt.Errorf("timestamp from longer than 3 days back: %v", d)
+	}
+	if d < -maxTimestampSkew {
+		return fmt.Errorf("timestamp %v in the future", -d)
+	}
+	return nil
+}
+
 type Event struct {
 	Name   string                 `json:"name"`
 	Params map[string]interface{} `json:"params"`
+	// Backdate the event, overriding Request.TimestampMicros
+	TimestampMicros int64 `json:"timestamp_micros,omitempty"`
+
+	// set by the constructors of reserved events this package supports
+	reservedOK bool
+	// cached encoding set by Freeze
+	raw []byte
 }
 
-func (e Event) validate() error {
-	if err := validName(e.Name, 40, reservedEventName, nil); err != nil {
-		return fmt.Errorf("invalid event name: %w", err)
+// UnmarshalJSON decodes integer params as int64 and other numbers as float64,
+// where json.Unmarshal would make all numbers float64.
+// The names of SessionStart and FirstVisit events are accepted like the constructors',
+// as their encodings can't be told apart.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Name            string                 `json:"name"`
+		Params          map[string]interface{} `json:"params"`
+		TimestampMicros int64                  `json:"timestamp_micros"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
 	}
-	if len(e.Params) > 25 {
-		return fmt.Errorf("event exceeds 25 params: %d", len(e.Params))
+	for k, p := range v.Params {
+		v.Params[k] = convertNumbers(p)
 	}
+	_, ctor := constructorEventNames[v.Name]
+	*e = Event{Name: v.Name, Params: v.Params, TimestampMicros: v.TimestampMicros, reservedOK: ctor}
+	return nil
+}
+
+// Freeze returns a copy of e with its JSON encoding cached,
+// for events that are sent repeatedly without changes.
+// Later changes to Params are not reflected in the encoding.
+// The cache is dropped when client options modify events before sending.
+func (e Event) Freeze() (Event, error) {
+	b, err := marshal(Event{Name: e.Name, Params: e.Params, TimestampMicros: e.TimestampMicros})
+	if err != nil {
+		return e, err
+	}
+	e.raw = b
+	return e, nil
+}
+
+// Size returns the length of the JSON encoding of e,
+// its contribution to the size of a request
+func (e Event) Size() (int, error) {
+	if e.raw != nil {
+		return len(e.raw), nil
+	}
+	b, err := marshal(e)
+	return len(b), err
+}
+
+func (e Event) clone() Event {
+	params := make(map[string]interface{}, len(e.Params)+1)
 	for k, v := range e.Params {
-		if err := validName(k, 40, reservedParamNames, reservedParamPrefix); err != nil {
+		params[k] = v
+	}
+	e.Params = params
+	e.raw = nil
+	return e
+}
+
+// Validate runs the checks a Client with Validate set runs on each event of a request,
+// for code that builds events outside of a Request
+func (e Event) Validate() error {
+	if err := e.checkLimits(defaultLimits); err != nil {
+		return err
+	}
+	if err := e.validate(); err != nil {
+		return err
+	}
+	if e.TimestampMicros != 0 {
+		return validTimestamp(e.TimestampMicros, time.Now())
+	}
+	return nil
+}
+
+func (e Event) validate() error {
+	if ctor, ok := constructorEventNames[strings.ToLower(e.Name)]; ok && !e.reservedOK {
+		return fmt.Errorf("invalid event name: %w, create it with %s", &NameError{Name: e.Name, Reason: NameReserved}, ctor)
+	}
+	if err := validName(e.Name, reservedEventName, nil); err != nil {
+		return fmt.Errorf("invalid event name: %w", err)
+	}
+	for k := range e.Params {
+		if _, ok := internalParams[k]; ok {
+			continue
+		}
+		if err := validName(k, reservedParamNames, reservedParamPrefix); err != nil {
 			return fmt.Errorf("invalid parameter name: %w", err)
 		}
-		switch vv := v.(type) {
-		case string:
-			if len(vv) > 100 {
-				return fmt.Errorf("parameter longer than 100: %q", vv)
+		if _, ok := adSettingParams[k]; ok {
+			return fmt.Errorf("parameter %q is a request level ad setting that can't vary per event: use Request.Consent, and SplitByConsent for events of different consent", k)
+		}
+		if _, ok := reservedUserProperties[k]; ok {
+			return fmt.Errorf("parameter %q is a user property, not an event parameter: user level data belongs in Request.UserProperties or Request.UserID", k)
+		}
+		if v, ok := e.Params[k].(string); ok {
+			if err := validText(v); err != nil {
+				return fmt.Errorf("invalid parameter %q: %w", k, err)
+			}
+		}
+	}
+	for _, k := range []string{"page_location", "page_referrer"} {
+		if v, ok := e.Params[k]; ok {
+			if err := validURL(v); err != nil {
+				return fmt.Errorf("invalid %s: %w", k, err)
 			}
 		}
 	}
+	if items, ok := e.Params["items"]; ok {
+		if err := validItems(items); err != nil {
+			return fmt.Errorf("invalid items: %w", err)
+		}
+	}
 	return nil
 }
 
 // reserved names
 // https://developers.google.com/analytics/devguides/collection/protocol/ga4/reference?client_type=gtag#reserved_names
 var (
+	// events that can't be sent
 	reservedEventName = map[string]struct{}{
 		"ad_activeview":           {},
 		"ad_click":                {},
@@ -208,7 +1627,6 @@ var (
 		"app_remove":              {},
 		"error":                   {},
 		"first_open":              {},
-		"first_visit":             {},
 		"in_app_purchase":         {},
 		"notification_dismiss":    {},
 		"notification_foreground": {},
@@ -216,10 +1634,16 @@ var (
 		"notification_receive":    {},
 		"os_update":               {},
 		"screen_view":             {},
-		"session_start":           {},
 		"user_engagement":         {},
 	}
 
+	// reserved events that can't be used as custom event names,
+	// but can be sent when created by their constructor
+	constructorEventNames = map[string]string{
+		"first_visit":   "FirstVisit",
+		"session_start": "SessionStart",
+	}
+
 	reservedParamNames = map[string]struct{}{
 		"firebase_conversion": {},
 	}
@@ -245,28 +1669,106 @@ var (
 	}
 )
 
-func validName(s string, l int, reservedNames, reservedPrefixes map[string]struct{}) error {
+// NameErrorReason is the rule a name violated
+type NameErrorReason int
+
+const (
+	NameTooLong NameErrorReason = iota + 1
+	NameReserved
+	NameReservedPrefix
+	NameBadFirstChar
+	NameIllegalChar
+	NameEmpty
+)
+
+// NameError is returned for invalid event, parameter, and user property names
+type NameError struct {
+	Name   string
+	Reason NameErrorReason
+
+	limit  int    // NameTooLong
+	prefix string // NameReservedPrefix
+	index  int    // NameIllegalChar
+}
+
+func (e *NameError) Error() string {
+	switch e.Reason {
+	case NameTooLong:
+		return fmt.Sprintf("name longer than %v: %q", e.limit, e.Name)
+	case NameReserved:
+		return fmt.Sprintf("name is reserved: %q", e.Name)
+	case NameReservedPrefix:
+		return fmt.Sprintf("name has reserved prefix %q: %q", e.prefix, e.Name)
+	case NameBadFirstChar:
+		return fmt.Sprintf("name must begin with alphabetic char: %q", e.Name)
+	case NameIllegalChar:
+		return fmt.Sprintf("illegal char index %d: %q", e.index, e.Name)
+	case NameEmpty:
+		return "name is empty"
+	}
+	return fmt.Sprintf("invalid name: %q", e.Name)
+}
+
+// reservedParam reports whether k is a reserved param name or has a reserved prefix,
+// other than the internal params this package sets
+func reservedParam(k string) bool {
+	if _, ok := internalParams[k]; ok {
+		return false
+	}
+	var ne *NameError
+	if errors.As(validName(k, reservedParamNames, reservedParamPrefix), &ne) {
+		return ne.Reason == NameReserved || ne.Reason == NameReservedPrefix
+	}
+	return false
+}
+
+func nameLen(s string, l int) error {
 	if len(s) > l {
-		return fmt.Errorf("name longer than %v: %q", l, s)
+		return &NameError{Name: s, Reason: NameTooLong, limit: l}
 	}
-	if _, ok := reservedNames[s]; ok {
-		return fmt.Errorf("name is reserved: %q", s)
+	return nil
+}
+
+func validName(s string, reservedNames, reservedPrefixes map[string]struct{}) error {
+	if s == "" {
+		return &NameError{Name: s, Reason: NameEmpty}
+	}
+	// reserved names and prefixes apply regardless of case
+	lower := strings.ToLower(s)
+	if _, ok := reservedNames[lower]; ok {
+		return &NameError{Name: s, Reason: NameReserved}
 	}
 	for prefix := range reservedPrefixes {
-		if strings.HasPrefix(s, prefix) {
-			return fmt.Errorf("name has reserved prefix %q: %q", prefix, s)
+		if strings.HasPrefix(lower, prefix) {
+			return &NameError{Name: s, Reason: NameReservedPrefix, prefix: prefix}
 		}
 	}
 	for i, r := range s {
 		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
 			continue
-		} else if (r >= '0' && r <= 'Z') || r == '_' {
+		} else if (r >= '0' && r <= '9') || r == '_' {
 			if i == 0 {
-				return fmt.Errorf("name must begin with alphabetic char: %q", s)
+				return &NameError{Name: s, Reason: NameBadFirstChar}
 			}
 			continue
 		}
-		return fmt.Errorf("illegal char index %d: %q", i, s)
+		return &NameError{Name: s, Reason: NameIllegalChar, index: i}
+	}
+	return nil
+}
+
+// validURL checks v is an absolute http(s) URL
+func validURL(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("%T is not a string", v)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("not an absolute http(s) url: %q", s)
 	}
 	return nil
 }
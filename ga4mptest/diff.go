@@ -0,0 +1,153 @@
+// Package ga4mptest has helpers for testing code that builds ga4mp requests
+package ga4mptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rdbell/ga4mp"
+)
+
+// DiffRequests returns a line per difference between want and got,
+// empty when they are equal.
+// Maps are compared by key, and values by their JSON encoding,
+// so an int and a float64 with the same value are equal.
+func DiffRequests(want, got *ga4mp.Request) string {
+	d := &differ{}
+	if want == nil || got == nil {
+		if want != got {
+			d.add("request", want, got)
+		}
+		return d.String()
+	}
+	want, got = decodeRaw(want), decodeRaw(got)
+
+	d.value("client_id", want.ClientID, got.ClientID)
+	d.value("app_instance_id", want.AppInstanceID, got.AppInstanceID)
+	d.value("user_id", want.UserID, got.UserID)
+	d.value("timestamp_micros", want.TimestampMicros, got.TimestampMicros)
+	d.value("non_personalized_ads", want.NonPersonalizedAds, got.NonPersonalizedAds)
+	d.value("consent", want.Consent, got.Consent)
+	d.value("user_location", want.UserLocation, got.UserLocation)
+	d.value("device", want.Device, got.Device)
+	d.value("ip_override", want.IPOverride, got.IPOverride)
+	d.value("user_agent", want.UserAgentOverride, got.UserAgentOverride)
+
+	wp := make(map[string]interface{}, len(want.UserProperties))
+	for k, v := range want.UserProperties {
+		wp[k] = v
+	}
+	gp := make(map[string]interface{}, len(got.UserProperties))
+	for k, v := range got.UserProperties {
+		gp[k] = v
+	}
+	d.params("user_properties", wp, gp)
+
+	d.params("extra", want.Extra, got.Extra)
+
+	n := len(want.Events)
+	if len(got.Events) < n {
+		n = len(got.Events)
+	}
+	for i := 0; i < n; i++ {
+		path := fmt.Sprintf("events[%d]", i)
+		d.value(path+".name", want.Events[i].Name, got.Events[i].Name)
+		d.value(path+".timestamp_micros", want.Events[i].TimestampMicros, got.Events[i].TimestampMicros)
+		d.params(path+".params", want.Events[i].Params, got.Events[i].Params)
+	}
+	for i := n; i < len(want.Events); i++ {
+		d.add(fmt.Sprintf("events[%d]", i), want.Events[i].Name, nil)
+	}
+	for i := n; i < len(got.Events); i++ {
+		d.add(fmt.Sprintf("events[%d]", i), nil, got.Events[i].Name)
+	}
+	return d.String()
+}
+
+// decodeRaw returns r with the events set by Request.SetRawEvents decoded,
+// or r itself if it has none
+func decodeRaw(r *ga4mp.Request) *ga4mp.Request {
+	if len(r.Events) > 0 {
+		return r
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return r
+	}
+	rr, err := ga4mp.UnmarshalRequest(b)
+	if err != nil || len(rr.Events) == 0 {
+		return r
+	}
+	return rr
+}
+
+type differ struct {
+	lines []string
+}
+
+func (d *differ) String() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// add records a difference, nil standing for a missing value
+func (d *differ) add(path string, want, got interface{}) {
+	d.lines = append(d.lines, fmt.Sprintf("%s: want %s, got %s", path, show(want), show(got)))
+}
+
+func (d *differ) value(path string, want, got interface{}) {
+	if canonical(want) != canonical(got) {
+		d.add(path, want, got)
+	}
+}
+
+func (d *differ) params(path string, want, got map[string]interface{}) {
+	keys := make(map[string]struct{}, len(want)+len(got))
+	for k := range want {
+		keys[k] = struct{}{}
+	}
+	for k := range got {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		wv, wok := want[k]
+		gv, gok := got[k]
+		p := fmt.Sprintf("%s[%q]", path, k)
+		switch {
+		case !wok:
+			d.add(p, nil, gv)
+		case !gok:
+			d.add(p, wv, nil)
+		default:
+			d.value(p, wv, gv)
+		}
+	}
+}
+
+func show(v interface{}) string {
+	if v == nil {
+		return "missing"
+	}
+	return canonical(v)
+}
+
+// canonical returns the JSON encoding of v with sorted object keys
+func canonical(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return string(b)
+	}
+	b, _ = json.Marshal(tree)
+	return string(b)
+}
@@ -0,0 +1,71 @@
+package ga4mptest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rdbell/ga4mp"
+)
+
+// MemorySink is a ga4mp.Sender that keeps requests in memory instead of sending them
+type MemorySink struct {
+	// Fail requests that don't pass Request.Validate, like a Client with Validate set
+	Validate bool
+
+	mu       sync.Mutex
+	requests []*ga4mp.Request
+}
+
+var _ ga4mp.Sender = (*MemorySink)(nil)
+
+// Send stores a copy of r, with raw events decoded
+func (s *MemorySink) Send(ctx context.Context, r *ga4mp.Request) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.Validate {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	rr := *decodeRaw(r)
+	events := rr.Events
+	rr.Events = make([]ga4mp.Event, len(events))
+	for i, e := range events {
+		params := make(map[string]interface{}, len(e.Params))
+		for k, v := range e.Params {
+			params[k] = v
+		}
+		e.Params = params
+		rr.Events[i] = e
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, &rr)
+	s.mu.Unlock()
+	return nil
+}
+
+// Requests returns the stored requests in the order they were sent
+func (s *MemorySink) Requests() []*ga4mp.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ga4mp.Request(nil), s.requests...)
+}
+
+// Events returns the events of all stored requests
+func (s *MemorySink) Events() []ga4mp.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []ga4mp.Event
+	for _, r := range s.requests {
+		events = append(events, r.Events...)
+	}
+	return events
+}
+
+// Reset discards the stored requests
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	s.requests = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,127 @@
+package ga4mp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maximum number of items per event
+const maxItems = 200
+
+// Item is an entry of the items parameter for ecommerce events,
+// use a []Item as the value of Params["items"].
+// https://developers.google.com/analytics/devguides/collection/protocol/ga4/reference/events#purchase_item
+//
+// Quantity and Index are JSON integers,
+// Price and Discount are JSON numbers in the event currency.
+type Item struct {
+	// One of ItemID or ItemName is required
+	ItemID   string `json:"item_id,omitempty"`
+	ItemName string `json:"item_name,omitempty"`
+
+	Affiliation   string  `json:"affiliation,omitempty"`
+	Coupon        string  `json:"coupon,omitempty"`
+	Discount      float64 `json:"discount,omitempty"`
+	Index         int     `json:"index,omitempty"`
+	ItemBrand     string  `json:"item_brand,omitempty"`
+	ItemCategory  string  `json:"item_category,omitempty"`
+	ItemCategory2 string  `json:"item_category2,omitempty"`
+	ItemCategory3 string  `json:"item_category3,omitempty"`
+	ItemCategory4 string  `json:"item_category4,omitempty"`
+	ItemCategory5 string  `json:"item_category5,omitempty"`
+	ItemListID    string  `json:"item_list_id,omitempty"`
+	ItemListName  string  `json:"item_list_name,omitempty"`
+	ItemVariant   string  `json:"item_variant,omitempty"`
+	LocationID    string  `json:"location_id,omitempty"`
+	Price         float64 `json:"price,omitempty"`
+	Quantity      int     `json:"quantity,omitempty"`
+}
+
+// validItems checks the items param is an array of objects,
+// each with an item_id or item_name, at most 200 of them
+func validItems(v interface{}) error {
+	switch vv := v.(type) {
+	case []Item:
+		return ValidateItems(vv)
+	case []map[string]interface{}:
+		if len(vv) > maxItems {
+			return fmt.Errorf("%d items, max %d", len(vv), maxItems)
+		}
+		for i, item := range vv {
+			if err := validItemMap(i, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		// decoded from JSON
+		if len(vv) > maxItems {
+			return fmt.Errorf("%d items, max %d", len(vv), maxItems)
+		}
+		for i, item := range vv {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("item %d is %T, not an object", i, item)
+			}
+			if err := validItemMap(i, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("items is %T, not []Item", v)
+}
+
+// validItemMap checks item i has an item_id or item_name, like ValidateItems
+func validItemMap(i int, item map[string]interface{}) error {
+	for _, k := range []string{"item_id", "item_name"} {
+		if v, ok := item[k]; ok && v != "" && v != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("item %d has no item_id or item_name", i)
+}
+
+// ValidateItems checks items has at most 200 entries,
+// each with an ItemID or ItemName and no negative Quantity
+func ValidateItems(items []Item) error {
+	if len(items) > maxItems {
+		return fmt.Errorf("%d items, max %d", len(items), maxItems)
+	}
+	for i, item := range items {
+		if item.ItemID == "" && item.ItemName == "" {
+			return fmt.Errorf("item %d has no item_id or item_name", i)
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("item %d has negative quantity %d", i, item.Quantity)
+		}
+	}
+	return nil
+}
+
+// NormalizeItems returns a copy of items that passes ValidateItems:
+// strings are trimmed of spaces, items with no ItemID or ItemName are dropped,
+// negative quantities become 0, and only the first 200 items are kept
+func NormalizeItems(items []Item) []Item {
+	out := make([]Item, 0, len(items))
+	for _, item := range items {
+		if len(out) == maxItems {
+			break
+		}
+		for _, f := range []*string{
+			&item.ItemID, &item.ItemName, &item.Affiliation, &item.Coupon, &item.ItemBrand,
+			&item.ItemCategory, &item.ItemCategory2, &item.ItemCategory3, &item.ItemCategory4, &item.ItemCategory5,
+			&item.ItemListID, &item.ItemListName, &item.ItemVariant, &item.LocationID,
+		} {
+			*f = strings.TrimSpace(*f)
+		}
+		if item.ItemID == "" && item.ItemName == "" {
+			continue
+		}
+		if item.Quantity < 0 {
+			item.Quantity = 0
+		}
+		out = append(out, item)
+	}
+	return out
+}
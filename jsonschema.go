@@ -0,0 +1,194 @@
+package ga4mp
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// JSONSchema returns a JSON Schema (draft-07) of the requests this package sends,
+// with the documented limits, for validating payloads outside of Go.
+// It covers the structure, limits and reserved names,
+// which are matched regardless of case like Request.Validate does.
+// The event names of SessionStart and FirstVisit are allowed as those produce them,
+// the other lints of Request.Validate, such as timestamp windows, aren't expressible.
+func JSONSchema() []byte {
+	name := func(maxLen int) map[string]interface{} {
+		return map[string]interface{}{
+			"type":      "string",
+			"maxLength": maxLen,
+			"pattern":   "^[A-Za-z][A-Za-z0-9_]*$",
+		}
+	}
+	notReserved := func(names, prefixes map[string]struct{}) map[string]interface{} {
+		var not []interface{}
+		if len(names) > 0 {
+			not = append(not, map[string]interface{}{"pattern": "^(" + caseless(names) + ")$"})
+		}
+		if len(prefixes) > 0 {
+			not = append(not, map[string]interface{}{"pattern": "^(" + caseless(prefixes) + ")"})
+		}
+		return map[string]interface{}{"not": map[string]interface{}{"anyOf": not}}
+	}
+
+	paramName := map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"enum": sortedNames(internalParams)},
+			map[string]interface{}{"allOf": []interface{}{
+				name(maxParamNameLen),
+				notReserved(reservedParamNames, reservedParamPrefix),
+				map[string]interface{}{"not": map[string]interface{}{"enum": sortedNames(reservedUserProperties)}},
+			}},
+		},
+	}
+	paramProps := map[string]interface{}{
+		"items": map[string]interface{}{
+			"type":     "array",
+			"maxItems": maxItems,
+			"items":    map[string]interface{}{"$ref": "#/definitions/item"},
+		},
+	}
+	for k, l := range paramValueLens {
+		paramProps[k] = map[string]interface{}{"type": "string", "maxLength": l}
+	}
+
+	item := structSchema(reflect.TypeOf(Item{}))
+	item["anyOf"] = []interface{}{
+		map[string]interface{}{"required": []string{"item_id"}},
+		map[string]interface{}{"required": []string{"item_name"}},
+	}
+	consent := structSchema(reflect.TypeOf(Consent{}))
+	for _, p := range consent["properties"].(map[string]interface{}) {
+		p.(map[string]interface{})["enum"] = []string{ConsentGranted, ConsentDenied}
+	}
+
+	schema := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "GA4 Measurement Protocol request",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"client_id":        map[string]interface{}{"type": "string"},
+			"app_instance_id":  map[string]interface{}{"type": "string"},
+			"user_id":          map[string]interface{}{"type": "string"},
+			"timestamp_micros": map[string]interface{}{"type": "integer"},
+			"user_properties": map[string]interface{}{
+				"type":          []string{"object", "null"},
+				"maxProperties": maxUserProperties,
+				"propertyNames": map[string]interface{}{"allOf": []interface{}{
+					name(maxUserPropertyNameLen),
+					notReserved(reservedUserProperties, reservedUserPropertyPrefix),
+				}},
+				"additionalProperties": map[string]interface{}{"type": "string", "maxLength": maxUserPropertyValueLen},
+			},
+			"non_personalized_ads": map[string]interface{}{"type": "boolean"},
+			"consent":              consent,
+			"events": map[string]interface{}{
+				"type":     "array",
+				"maxItems": maxEvents,
+				"items":    map[string]interface{}{"$ref": "#/definitions/event"},
+			},
+			"user_location": structSchema(reflect.TypeOf(UserLocation{})),
+			"device":        structSchema(reflect.TypeOf(Device{})),
+			"ip_override":   map[string]interface{}{"type": "string"},
+			"user_agent":    map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"events"},
+		"oneOf": []interface{}{
+			map[string]interface{}{"required": []string{"client_id"}},
+			map[string]interface{}{"required": []string{"app_instance_id"}},
+		},
+		"definitions": map[string]interface{}{
+			"event": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"name"},
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"anyOf": []interface{}{
+						map[string]interface{}{"enum": sortedNames(constructorNames())},
+						map[string]interface{}{"allOf": []interface{}{
+							name(maxEventNameLen),
+							notReserved(reservedEventName, nil),
+							notReserved(constructorNames(), nil),
+						}},
+					}},
+					"params": map[string]interface{}{
+						"type":          []string{"object", "null"},
+						"maxProperties": maxEventParams,
+						"propertyNames": paramName,
+						"properties":    paramProps,
+						"additionalProperties": map[string]interface{}{"anyOf": []interface{}{
+							map[string]interface{}{"type": "string", "maxLength": maxParamValueLen},
+							map[string]interface{}{"type": []string{"number", "boolean"}},
+						}},
+					},
+					"timestamp_micros": map[string]interface{}{"type": "integer"},
+				},
+			},
+			"item": item,
+		},
+	}
+	b, _ := json.MarshalIndent(schema, "", "  ")
+	return b
+}
+
+// structSchema describes the JSON encoding of a struct of strings and numbers
+func structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		typ := "string"
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int64:
+			typ = "integer"
+		case reflect.Float64:
+			typ = "number"
+		}
+		props[name] = map[string]interface{}{"type": typ}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+}
+
+// constructorNames are the event names only the constructors can send
+func constructorNames() map[string]struct{} {
+	names := make(map[string]struct{}, len(constructorEventNames))
+	for n := range constructorEventNames {
+		names[n] = struct{}{}
+	}
+	return names
+}
+
+// caseless returns a regular expression alternation of the names in m
+// matching any letter case, as JSON Schema patterns have no case insensitive flag
+func caseless(m map[string]struct{}) string {
+	names := sortedNames(m)
+	for i, n := range names {
+		var b strings.Builder
+		for _, r := range n {
+			if lower, upper := unicode.ToLower(r), unicode.ToUpper(r); lower != upper {
+				b.WriteString("[" + string(lower) + string(upper) + "]")
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		names[i] = b.String()
+	}
+	return strings.Join(names, "|")
+}
+
+func sortedNames(m map[string]struct{}) []string {
+	names := make([]string, 0, len(m))
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
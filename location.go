@@ -0,0 +1,45 @@
+package ga4mp
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// UserLocation overrides the geographic information of a request
+// https://developers.google.com/analytics/devguides/collection/protocol/ga4/reference#user_location
+type UserLocation struct {
+	City string `json:"city,omitempty"`
+	// ISO 3166-2 subdivision, eg. US-CA
+	RegionID string `json:"region_id,omitempty"`
+	// ISO 3166-1 alpha-2 country, eg. US
+	CountryID string `json:"country_id,omitempty"`
+	// UN M49 subcontinent, eg. 021
+	SubcontinentID string `json:"subcontinent_id,omitempty"`
+	// UN M49 continent, eg. 019
+	ContinentID string `json:"continent_id,omitempty"`
+}
+
+var (
+	countryRe = regexp.MustCompile(`^[A-Z]{2}$`)
+	regionRe  = regexp.MustCompile(`^[A-Z]{2}-[A-Z0-9]{1,3}$`)
+	m49Re     = regexp.MustCompile(`^[0-9]{3}$`)
+)
+
+func (l UserLocation) validate() error {
+	if l.RegionID != "" && !regionRe.MatchString(l.RegionID) {
+		return fmt.Errorf("region_id not an ISO 3166-2 code: %q", l.RegionID)
+	}
+	if l.CountryID != "" && !countryRe.MatchString(l.CountryID) {
+		return fmt.Errorf("country_id not an ISO 3166-1 alpha-2 code: %q", l.CountryID)
+	}
+	if l.RegionID != "" && l.CountryID != "" && l.RegionID[:2] != l.CountryID {
+		return fmt.Errorf("region_id %q not in country_id %q", l.RegionID, l.CountryID)
+	}
+	if l.SubcontinentID != "" && !m49Re.MatchString(l.SubcontinentID) {
+		return fmt.Errorf("subcontinent_id not a UN M49 code: %q", l.SubcontinentID)
+	}
+	if l.ContinentID != "" && !m49Re.MatchString(l.ContinentID) {
+		return fmt.Errorf("continent_id not a UN M49 code: %q", l.ContinentID)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package ga4mp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Params checks the keys and values of a generic map,
+// such as one decoded from JSON, and returns a copy to use as Event.Params.
+// Integers become int64 and other numbers float64,
+// strings and bools are kept, and the items param must be a valid list of items.
+// Other values, such as nested objects and nil, are rejected.
+func Params(m map[string]interface{}) (map[string]interface{}, error) {
+	params := make(map[string]interface{}, len(m))
+	// sorted to report the same error for the same map
+	for _, k := range sortedKeys(m) {
+		if _, ok := internalParams[k]; !ok {
+			if err := validName(k, reservedParamNames, reservedParamPrefix); err != nil {
+				return nil, fmt.Errorf("invalid parameter name: %w", err)
+			}
+		}
+		if k == "items" {
+			if err := validItems(m[k]); err != nil {
+				return nil, fmt.Errorf("invalid items: %w", err)
+			}
+			params[k] = m[k]
+			continue
+		}
+		v, err := paramValue(m[k])
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", k, err)
+		}
+		params[k] = v
+	}
+	return params, nil
+}
+
+func paramValue(v interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case string, bool:
+		return v, nil
+	case int:
+		return int64(vv), nil
+	case int8:
+		return int64(vv), nil
+	case int16:
+		return int64(vv), nil
+	case int32:
+		return int64(vv), nil
+	case int64:
+		return vv, nil
+	case uint:
+		return paramValue(uint64(vv))
+	case uint8:
+		return int64(vv), nil
+	case uint16:
+		return int64(vv), nil
+	case uint32:
+		return int64(vv), nil
+	case uint64:
+		if vv > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int64", vv)
+		}
+		return int64(vv), nil
+	case float32:
+		return paramValue(float64(vv))
+	case float64:
+		if math.IsNaN(vv) || math.IsInf(vv, 0) {
+			return nil, fmt.Errorf("%v is not a JSON number", vv)
+		}
+		return vv, nil
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := vv.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
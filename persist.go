@@ -0,0 +1,123 @@
+package ga4mp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MarshalRequest encodes r for storage, to be read back with UnmarshalRequest,
+// for example to replay a failing request later.
+// Unlike json.Marshal, float params with integral values keep a decimal point,
+// so integer, float and string params keep their types through the round trip.
+// Items decode as a []interface{} of maps.
+func MarshalRequest(r *Request) ([]byte, error) {
+	rr := r.clone()
+	for _, e := range rr.Events {
+		for k, v := range e.Params {
+			e.Params[k] = markFloats(v)
+		}
+	}
+	b, err := encodeRequest(rr)
+	if err != nil {
+		return nil, fmt.Errorf("ga4mp: marshal request: %w", err)
+	}
+	return b, nil
+}
+
+// UnmarshalRequest decodes a request encoded by MarshalRequest,
+// or any JSON request, with integer params as int64 and other numbers as float64
+// like Event.UnmarshalJSON.
+// Unknown top level fields are kept in Extra.
+func UnmarshalRequest(b []byte) (*Request, error) {
+	r, err := unmarshalRequest(b)
+	if err != nil {
+		return nil, fmt.Errorf("ga4mp: unmarshal request: %w", err)
+	}
+	return r, nil
+}
+
+func unmarshalRequest(b []byte) (*Request, error) {
+	var r Request
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for k, raw := range fields {
+		if _, ok := requestFields[k]; ok {
+			continue
+		}
+		v, err := decodeNumbers(raw)
+		if err != nil {
+			return nil, err
+		}
+		if r.Extra == nil {
+			r.Extra = make(map[string]interface{})
+		}
+		r.Extra[k] = v
+	}
+	return &r, nil
+}
+
+// decodeNumbers decodes a JSON value like UnmarshalRequest
+func decodeNumbers(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertNumbers(v), nil
+}
+
+// markFloats returns a copy of v with integral floats encoding as 1.0 instead of 1
+func markFloats(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case float64:
+		if vv == math.Trunc(vv) && !math.IsInf(vv, 0) && math.Abs(vv) < 1e21 {
+			return json.Number(strconv.FormatFloat(vv, 'f', 1, 64))
+		}
+	case float32:
+		return markFloats(float64(vv))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, e := range vv {
+			m[k] = markFloats(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(vv))
+		for i, e := range vv {
+			s[i] = markFloats(e)
+		}
+		return s
+	}
+	return v
+}
+
+// convertNumbers replaces the json.Numbers in a decoded JSON value
+// with int64 for integers and float64 otherwise
+func convertNumbers(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return i
+		}
+		f, _ := vv.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range vv {
+			vv[k] = convertNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range vv {
+			vv[i] = convertNumbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package ga4mp
+
+import "fmt"
+
+// Schema is a tracking plan listing the allowed events and their params.
+// Params added by client options, such as DefaultParams, must be listed too.
+type Schema struct {
+	Events map[string]EventSchema
+}
+
+type EventSchema struct {
+	Params map[string]ParamType
+}
+
+// ParamType is the expected type of a param value
+type ParamType int
+
+const (
+	ParamAny ParamType = iota
+	ParamString
+	// any number
+	ParamNumber
+	// integer types only
+	ParamInteger
+	ParamBool
+	// []Item or an equivalent array of objects
+	ParamItems
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case ParamAny:
+		return "any"
+	case ParamString:
+		return "string"
+	case ParamNumber:
+		return "number"
+	case ParamInteger:
+		return "integer"
+	case ParamBool:
+		return "bool"
+	case ParamItems:
+		return "items"
+	}
+	return fmt.Sprintf("ParamType(%d)", int(t))
+}
+
+func (t ParamType) matches(v interface{}) bool {
+	switch t {
+	case ParamAny:
+		return true
+	case ParamString:
+		_, ok := v.(string)
+		return ok
+	case ParamNumber:
+		switch v.(type) {
+		case float32, float64:
+			return true
+		}
+		return ParamInteger.matches(v)
+	case ParamInteger:
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		}
+	case ParamBool:
+		_, ok := v.(bool)
+		return ok
+	case ParamItems:
+		return validItems(v) == nil
+	}
+	return false
+}
+
+func (s *Schema) validate(r *Request) error {
+	for i, e := range r.Events {
+		es, ok := s.Events[e.Name]
+		if !ok {
+			return eventError(i, e, fmt.Errorf("event not in schema"))
+		}
+		for k, v := range e.Params {
+			if k == dropDiagnosticParam {
+				continue
+			}
+			t, ok := es.Params[k]
+			if !ok {
+				return eventError(i, e, fmt.Errorf("parameter %q not in schema", k))
+			}
+			if !t.matches(v) {
+				return eventError(i, e, fmt.Errorf("parameter %q is %T, schema wants %v", k, v, t))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package ga4mp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Redacted replaces values flagged by a Scrubber
+const Redacted = "[redacted]"
+
+// Scrubber checks string event params and user property values
+// for personal information before sending.
+type Scrubber struct {
+	// Detect reports whether a value should be scrubbed,
+	// defaults to LooksLikePII
+	Detect func(s string) bool
+	// Fail the request instead of redacting the value
+	Reject bool
+}
+
+var (
+	emailRe = regexp.MustCompile(`[^\s@]+@[^\s@]+\.[^\s@]+`)
+	phoneRe = regexp.MustCompile(`\+\d[\d\s().-]{6,}\d|\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`)
+	cardRe  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// LooksLikePII reports whether s contains something shaped like
+// an email address, phone number, or payment card number.
+func LooksLikePII(s string) bool {
+	if emailRe.MatchString(s) || phoneRe.MatchString(s) {
+		return true
+	}
+	for _, m := range cardRe.FindAllString(s, -1) {
+		if luhn(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum
+func luhn(s string) bool {
+	var sum int
+	var double bool
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func (s *Scrubber) detect(v string) bool {
+	if s.Detect != nil {
+		return s.Detect(v)
+	}
+	return LooksLikePII(v)
+}
+
+// scrub redacts or rejects the values of r in place
+func (s *Scrubber) scrub(r *Request, warn func(string, ...interface{})) error {
+	for k, v := range r.UserProperties {
+		if !s.detect(v) {
+			continue
+		}
+		if s.Reject {
+			return fmt.Errorf("user property %q looks like personal information", k)
+		}
+		r.UserProperties[k] = Redacted
+		warn("ga4mp: redacted user property %q", k)
+	}
+	for _, e := range r.Events {
+		for k, v := range e.Params {
+			vv, ok := v.(string)
+			if !ok || !s.detect(vv) {
+				continue
+			}
+			if s.Reject {
+				return fmt.Errorf("event %q parameter %q looks like personal information", e.Name, k)
+			}
+			e.Params[k] = Redacted
+			warn("ga4mp: redacted event %q parameter %q", e.Name, k)
+		}
+	}
+	return nil
+}
+
+// UserIDPolicy checks user ids for personal information,
+// which GA4 policy prohibits in user_id.
+// User ids are never redacted, that would merge users.
+type UserIDPolicy struct {
+	// Detect reports whether a user id is personal information,
+	// defaults to matching email addresses and phone numbers
+	Detect func(s string) bool
+	// Fail the request, instead of reporting the user id through Warn
+	Strict bool
+	// Check user property values too
+	UserProperties bool
+}
+
+func (p *UserIDPolicy) detect(v string) bool {
+	if p.Detect != nil {
+		return p.Detect(v)
+	}
+	return emailRe.MatchString(v) || phoneRe.MatchString(v)
+}
+
+func (p *UserIDPolicy) check(r *Request, warn func(string, ...interface{})) error {
+	if r.UserID != "" && p.detect(r.UserID) {
+		if p.Strict {
+			return fmt.Errorf("user_id looks like personal information")
+		}
+		warn("ga4mp: user_id looks like personal information")
+	}
+	if !p.UserProperties {
+		return nil
+	}
+	for _, k := range sortedUserProperties(r.UserProperties) {
+		if !p.detect(r.UserProperties[k]) {
+			continue
+		}
+		if p.Strict {
+			return fmt.Errorf("user property %q looks like personal information", k)
+		}
+		warn("ga4mp: user property %q looks like personal information", k)
+	}
+	return nil
+}
+
+func sortedUserProperties(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
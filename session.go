@@ -0,0 +1,80 @@
+package ga4mp
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionTracker assigns server side events to sessions per client id,
+// like the tag does on the client:
+// a session ends after a timeout without events,
+// and the next event starts a new one with a new session_id
+// and an incremented ga_session_number.
+// It keeps the last session of every client id it has seen in memory,
+// use Forget for users that won't come back.
+type SessionTracker struct {
+	timeout time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+type session struct {
+	id     int64
+	number int64
+	last   time.Time
+}
+
+// NewSessionTracker returns a tracker ending sessions after timeout without events,
+// defaulting to GA4's 30 minutes
+func NewSessionTracker(timeout time.Duration) *SessionTracker {
+	if timeout <= 0 {
+		timeout = 30 * time.Minute
+	}
+	return &SessionTracker{
+		timeout:  timeout,
+		now:      time.Now,
+		sessions: make(map[string]*session),
+	}
+}
+
+// Stamp returns copies of events for clientID with the session_id and ga_session_number params
+// of the current session, the first event of a new session is flagged with WithNewSession
+func (t *SessionTracker) Stamp(clientID string, events ...Event) []Event {
+	now := t.now()
+	t.mu.Lock()
+	s, ok := t.sessions[clientID]
+	if !ok {
+		s = &session{}
+		t.sessions[clientID] = s
+	}
+	start := s.number == 0 || now.Sub(s.last) > t.timeout
+	if start {
+		s.id = now.Unix()
+		s.number++
+	}
+	s.last = now
+	id, number := s.id, s.number
+	t.mu.Unlock()
+
+	stamped := make([]Event, len(events))
+	for i, e := range events {
+		e = e.clone()
+		e.Params["session_id"] = id
+		e.Params["ga_session_number"] = number
+		if start && i == 0 {
+			e.Params["_nsi"] = 1
+		}
+		stamped[i] = e
+	}
+	return stamped
+}
+
+// Forget drops the session state of clientID,
+// their next event starts session number 1
+func (t *SessionTracker) Forget(clientID string) {
+	t.mu.Lock()
+	delete(t.sessions, clientID)
+	t.mu.Unlock()
+}
@@ -0,0 +1,38 @@
+package ga4mp
+
+import "sync/atomic"
+
+// Stats counts the requests a Client made to the collect endpoint,
+// after splitting and batching. Debug clients count nothing.
+type Stats struct {
+	// Requests accepted with a 2xx response, and their events
+	SentRequests uint64
+	SentEvents   uint64
+	// Requests that failed before or after reaching the endpoint
+	FailedRequests uint64
+}
+
+// counters are updated atomically, allocated separately for 64 bit alignment
+type counters struct {
+	sentRequests   uint64
+	sentEvents     uint64
+	failedRequests uint64
+}
+
+func (n *counters) record(events int, err error) {
+	if err != nil {
+		atomic.AddUint64(&n.failedRequests, 1)
+		return
+	}
+	atomic.AddUint64(&n.sentRequests, 1)
+	atomic.AddUint64(&n.sentEvents, uint64(events))
+}
+
+// Stats returns a snapshot of the counters of c
+func (c *Client) Stats() Stats {
+	return Stats{
+		SentRequests:   atomic.LoadUint64(&c.counters.sentRequests),
+		SentEvents:     atomic.LoadUint64(&c.counters.sentEvents),
+		FailedRequests: atomic.LoadUint64(&c.counters.failedRequests),
+	}
+}
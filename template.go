@@ -0,0 +1,118 @@
+package ga4mp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Template is a JSON encoded Request
+// with ${name} placeholders in its string values
+type Template struct {
+	tree interface{}
+	vars map[string]struct{}
+}
+
+var placeholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func ParseTemplate(data []byte) (*Template, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("ga4mp: parse template: %w", err)
+	}
+	if _, ok := tree.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("ga4mp: parse template: not a JSON object")
+	}
+	t := &Template{tree: tree, vars: make(map[string]struct{})}
+	walkStrings(tree, func(s string) string {
+		for _, m := range placeholderRe.FindAllStringSubmatch(s, -1) {
+			t.vars[m[1]] = struct{}{}
+		}
+		return s
+	})
+	return t, nil
+}
+
+// Vars returns the sorted names of the placeholders in t
+func (t *Template) Vars() []string {
+	vars := make([]string, 0, len(t.vars))
+	for v := range t.vars {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// Render substitutes vars into the placeholders of t,
+// returning the resulting validated Request.
+// Every placeholder must have a value.
+// Unknown top level fields are kept in Extra, like UnmarshalRequest.
+func (t *Template) Render(vars map[string]string) (*Request, error) {
+	var missing []string
+	for _, v := range t.Vars() {
+		if _, ok := vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("ga4mp: render template: missing vars: %s", strings.Join(missing, ", "))
+	}
+
+	tree := walkStrings(copyTree(t.tree), func(s string) string {
+		return placeholderRe.ReplaceAllStringFunc(s, func(p string) string {
+			return vars[p[2:len(p)-1]]
+		})
+	})
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("ga4mp: render template: %w", err)
+	}
+	r, err := unmarshalRequest(b)
+	if err != nil {
+		return nil, fmt.Errorf("ga4mp: render template: %w", err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("ga4mp: render template: %w", err)
+	}
+	return r, nil
+}
+
+// walkStrings replaces the string values in a decoded JSON tree with f
+func walkStrings(v interface{}, f func(string) string) interface{} {
+	switch vv := v.(type) {
+	case string:
+		return f(vv)
+	case map[string]interface{}:
+		for k, e := range vv {
+			vv[k] = walkStrings(e, f)
+		}
+	case []interface{}:
+		for i, e := range vv {
+			vv[i] = walkStrings(e, f)
+		}
+	}
+	return v
+}
+
+func copyTree(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, e := range vv {
+			m[k] = copyTree(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(vv))
+		for i, e := range vv {
+			s[i] = copyTree(e)
+		}
+		return s
+	}
+	return v
+}
@@ -0,0 +1,19 @@
+package ga4mp
+
+import "net/http"
+
+// DefaultH2Transport returns a clone of http.DefaultTransport
+// keeping up to 100 idle connections to the endpoint instead of 2.
+// The default transport already uses HTTP/2 when the server negotiates it,
+// the larger pool only matters for the HTTP/1.1 fallback,
+// where each concurrent request needs its own connection.
+// Use it through ClientOptions.HttpClient:
+//
+//	ga4mp.New(ga4mp.ClientOptions{
+//		HttpClient: &http.Client{Transport: ga4mp.DefaultH2Transport(), Timeout: 10 * time.Second},
+//	})
+func DefaultH2Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 100
+	return t
+}
@@ -0,0 +1,101 @@
+package ga4mp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FromUAParams maps the parameters of a Universal Analytics measurement protocol hit
+// to an equivalent GA4 request, to ease migrating callers built around UA hits.
+// The mapping is approximate, as GA4 has a different data model:
+//
+//	cid, uid, uip, ua         client_id, user_id, ip_override, user_agent
+//	t=event                   an event named after ea in snake_case,
+//	                          with event_category (ec), event_label (el) and value (ev)
+//	t=pageview                page_view with page_location (dl, or dh and dp),
+//	                          page_title (dt) and page_referrer (dr)
+//	t=exception               exception with description (exd) and fatal (exf)
+//
+// Other hit types and parameters are not supported,
+// the resulting request is validated.
+func FromUAParams(p map[string]string) (*Request, error) {
+	if p["cid"] == "" {
+		return nil, fmt.Errorf("ga4mp: from ua params: no cid")
+	}
+	r := &Request{
+		ClientID:          p["cid"],
+		UserID:            p["uid"],
+		IPOverride:        p["uip"],
+		UserAgentOverride: p["ua"],
+	}
+
+	var e Event
+	switch t := p["t"]; t {
+	case "event":
+		if p["ea"] == "" {
+			return nil, fmt.Errorf("ga4mp: from ua params: event with no ea")
+		}
+		e = Event{Name: snakeName(p["ea"]), Params: map[string]interface{}{}}
+		setParam(e.Params, "event_category", p["ec"])
+		setParam(e.Params, "event_label", p["el"])
+		if ev := p["ev"]; ev != "" {
+			v, err := strconv.ParseInt(ev, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("ga4mp: from ua params: ev not an integer: %q", ev)
+			}
+			e.Params["value"] = v
+		}
+	case "pageview":
+		e = Event{Name: "page_view", Params: map[string]interface{}{}}
+		loc := p["dl"]
+		if loc == "" && p["dh"] != "" {
+			loc = "https://" + p["dh"] + p["dp"]
+		}
+		setParam(e.Params, "page_location", loc)
+		setParam(e.Params, "page_title", p["dt"])
+		setParam(e.Params, "page_referrer", p["dr"])
+	case "exception":
+		e = Exception(p["exd"], p["exf"] == "1")
+	default:
+		return nil, fmt.Errorf("ga4mp: from ua params: unsupported hit type %q", t)
+	}
+	r.Events = []Event{e}
+
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("ga4mp: from ua params: %w", err)
+	}
+	return r, nil
+}
+
+func setParam(params map[string]interface{}, k, v string) {
+	if v != "" {
+		params[k] = v
+	}
+}
+
+// snakeName turns s into a lower case event name,
+// replacing runs of other characters with underscores
+func snakeName(s string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+		} else {
+			underscore = true
+		}
+	}
+	name := b.String()
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "e_" + name
+	}
+	if len(name) > maxEventNameLen {
+		name = strings.TrimRight(name[:maxEventNameLen], "_")
+	}
+	return name
+}
@@ -0,0 +1,116 @@
+package ga4mp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Warnings returns advisories about r that don't fail validation,
+// for conditions that make GA4 accept the request but drop or hide its data:
+// no events, events missing engagement_time_msec,
+// a value param without currency, numeric params sent as strings,
+// and timestamps outside the accepted window.
+func (r *Request) Warnings() []string {
+	return r.warnings(time.Now())
+}
+
+func (r *Request) warnings(now time.Time) []string {
+	r, err := r.decodeRawEvents()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var w []string
+	if len(r.Events) == 0 {
+		w = append(w, "request has no events")
+	}
+	if r.TimestampMicros != 0 && r.sharesTimestamp() {
+		if err := validTimestamp(r.TimestampMicros, now); err != nil {
+			w = append(w, fmt.Sprintf("request %v, its events without a timestamp of their own are dropped", err))
+		}
+	}
+	for i, e := range r.Events {
+		if _, ok := e.Params["engagement_time_msec"]; !ok {
+			w = append(w, fmt.Sprintf("events[%d] (%s): no engagement_time_msec, it doesn't appear in realtime or user activity reports", i, e.Name))
+		}
+		_, value := e.Params["value"]
+		_, currency := e.Params["currency"]
+		if value && !currency {
+			w = append(w, fmt.Sprintf("events[%d] (%s): value without currency, the value is ignored", i, e.Name))
+		}
+		if e.TimestampMicros != 0 {
+			if err := validTimestamp(e.TimestampMicros, now); err != nil {
+				w = append(w, fmt.Sprintf("events[%d] (%s): %v, it is dropped", i, e.Name, err))
+			}
+		}
+		for _, k := range sortedKeys(e.Params) {
+			if numericString(k, e.Params[k]) {
+				w = append(w, fmt.Sprintf("events[%d] (%s): %s is the string %q, send it as the number %v to aggregate", i, e.Name, k, e.Params[k], e.Params[k]))
+			}
+		}
+		if items, ok := e.Params["items"].([]interface{}); ok {
+			for j, item := range items {
+				m, _ := item.(map[string]interface{})
+				for _, k := range sortedKeys(m) {
+					if numericString(k, m[k]) {
+						w = append(w, fmt.Sprintf("events[%d] (%s): item %d %s is the string %q, send it as the number %v", i, e.Name, j, k, m[k], m[k]))
+					}
+				}
+			}
+		}
+	}
+	return w
+}
+
+// params GA4 aggregates as numbers
+var numericParams = map[string]struct{}{
+	"value":    {},
+	"price":    {},
+	"quantity": {},
+	"tax":      {},
+	"shipping": {},
+	"discount": {},
+}
+
+// numericString reports whether v is a string number for a numeric param k
+func numericString(k string, v interface{}) bool {
+	if _, ok := numericParams[k]; !ok {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// noEffect reports whether the Warnings of r mean none of its events are recorded
+func (r *Request) noEffect(now time.Time) bool {
+	if r.rawEvents != nil {
+		var err error
+		if r, err = r.decodeRawEvents(); err != nil {
+			return false
+		}
+	}
+	for _, e := range r.Events {
+		ts := e.TimestampMicros
+		if ts == 0 {
+			ts = r.TimestampMicros
+		}
+		if ts == 0 || validTimestamp(ts, now) == nil {
+			return false
+		}
+	}
+	return true
+}